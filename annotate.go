@@ -0,0 +1,118 @@
+package bencode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// annotateWidth is the number of bytes shown on a single line of Annotate.
+const annotateWidth = 16
+
+// Annotate returns a hexdump of the bencoded data, where the first line of every node is labelled
+// with its path and type. For example d3:keyl18:\x00\x01\x02abcdefghijklmnoi7eee is printed as
+//
+//	0x0000  64                                               |d               |  dict(1)
+//	0x0001  33 3a 6b 65 79                                   |3:key           |  key key(3)
+//	0x0006  6c                                               |l               |  key list(2)
+//	0x0007  31 38 3a 00 01 02 61 62 63 64 65 66 67 68 69 6a  |18:...abcdefghij|  key[0] string(18)
+//	0x0017  6b 6c 6d 6e 6f                                   |klmno           |
+//	0x001c  69 37 65                                         |i7e             |  key[1] int
+//	0x001f  65                                               |e               |  key end
+//	0x0020  65                                               |e               |  end
+//
+// Strings longer than a line continue on unlabelled lines, the closing byte of lists and dictionaries
+// is labelled with their path followed by end. Non-printable bytes of keys are escaped as \xNN.
+// Invalid data is dumped without labels followed by the error.
+func Annotate(data []byte) string {
+	a := annotator{data: data, digits: len(fmt.Sprintf("%x", len(data)))}
+	if a.digits < 4 {
+		a.digits = 4
+	}
+
+	root, err := Parse(data)
+	if err != nil {
+		a.rows(0, len(data), "")
+		a.sb.WriteString("error: " + err.Error() + "\n")
+		return a.sb.String()
+	}
+	a.node(root, Path{})
+	return a.sb.String()
+}
+
+type annotator struct {
+	sb     strings.Builder
+	data   []byte
+	digits int
+}
+
+// label joins the path and the description of the node, the root has no path.
+// Non-printable bytes of the keys are written as \xNN escapes, so binary keys do not break the rows.
+func label(path Path, desc string) string {
+	if len(path) == 0 {
+		return desc
+	}
+	var sb strings.Builder
+	for _, b := range []byte(path.String()) {
+		if b >= 0x20 && b < 0x7f {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "\\x%02x", b)
+		}
+	}
+	return sb.String() + " " + desc
+}
+
+// rows dumps data[start:end], only the first line gets the label.
+func (a *annotator) rows(start, end int, lbl string) {
+	for offset := start; offset < end; offset += annotateWidth {
+		chunkEnd := offset + annotateWidth
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		chunk := a.data[offset:chunkEnd]
+		hexBytes := make([]string, len(chunk))
+		ascii := make([]byte, len(chunk))
+		for i, b := range chunk {
+			hexBytes[i] = fmt.Sprintf("%02x", b)
+			ascii[i] = '.'
+			if b >= 0x20 && b < 0x7f {
+				ascii[i] = b
+			}
+		}
+		line := fmt.Sprintf("0x%0*x  %-*s  |%-*s|", a.digits, offset, annotateWidth*3-1, strings.Join(hexBytes, " "), annotateWidth, ascii)
+		if lbl != "" {
+			line += "  " + lbl
+		}
+		a.sb.WriteString(strings.TrimRight(line, " ") + "\n")
+		lbl = ""
+	}
+}
+
+func (a *annotator) node(v View, path Path) {
+	e := v.index[v.pos]
+	start, end := int(e.start), int(e.end)
+	switch v.State() {
+	case BnInt:
+		a.rows(start, end, label(path, "int"))
+	case BnString:
+		n, _ := v.Len()
+		a.rows(start, end, label(path, fmt.Sprintf("string(%d)", n)))
+	case BnList:
+		n, _ := v.Len()
+		a.rows(start, start+1, label(path, fmt.Sprintf("list(%d)", n)))
+		for elem, i := v.firstChild(), 0; i < n; elem, i = elem.sibling(), i+1 {
+			a.node(elem, path.Index(i))
+		}
+		a.rows(end-1, end, label(path, "end"))
+	case BnDict:
+		n, _ := v.Len()
+		a.rows(start, start+1, label(path, fmt.Sprintf("dict(%d)", n)))
+		for key, i := v.firstChild(), 0; i < n; key, i = key.sibling().sibling(), i+1 {
+			k, _ := key.Bytes()
+			ke := key.index[key.pos]
+			a.rows(int(ke.start), int(ke.end), label(path.Key(string(k)), fmt.Sprintf("key(%d)", len(k))))
+			a.node(key.sibling(), path.Key(string(k)))
+		}
+		a.rows(end-1, end, label(path, "end"))
+	}
+}
@@ -0,0 +1,64 @@
+package bencode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "Nested with a long binary string",
+			data: "d3:keyl18:\x00\x01\x02abcdefghijklmnoi7eee",
+			want: []string{
+				"0x0000  64                                               |d               |  dict(1)",
+				"0x0001  33 3a 6b 65 79                                   |3:key           |  key key(3)",
+				"0x0006  6c                                               |l               |  key list(2)",
+				"0x0007  31 38 3a 00 01 02 61 62 63 64 65 66 67 68 69 6a  |18:...abcdefghij|  key[0] string(18)",
+				"0x0017  6b 6c 6d 6e 6f                                   |klmno           |",
+				"0x001c  69 37 65                                         |i7e             |  key[1] int",
+				"0x001f  65                                               |e               |  key end",
+				"0x0020  65                                               |e               |  end",
+			},
+		},
+		{
+			name: "Binary key",
+			data: "d2:\x00\ni1ee",
+			want: []string{
+				"0x0000  64                                               |d               |  dict(1)",
+				"0x0001  32 3a 00 0a                                      |2:..            |  \\x00\\x0a key(2)",
+				"0x0005  69 31 65                                         |i1e             |  \\x00\\x0a int",
+				"0x0008  65                                               |e               |  end",
+			},
+		},
+		{
+			name: "Invalid data",
+			data: "d3:ab",
+			want: []string{
+				"0x0000  64 33 3a 61 62                                   |d3:ab           |",
+				"error: Offset 1: String of 3 bytes exceeds the data",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Split(strings.TrimSuffix(Annotate([]byte(tt.data)), "\n"), "\n")
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Annotate() = \n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestAnnotate_offsets(t *testing.T) {
+	got := Annotate([]byte(viewSample))
+	for _, want := range []string{"0x002a  31 30 3a", "info.name string(10)", "info.pieces[2] string(3)", "0x004e  65"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Annotate() does not contain %q", want)
+		}
+	}
+}
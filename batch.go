@@ -0,0 +1,100 @@
+package bencode
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Result is the outcome of decoding a single file by DecodeFiles.
+type Result struct {
+	Path string
+	Node BnCode
+	Err  error
+}
+
+// DecodeFiles decodes the files read from paths in parallel and sends a Result for each of them.
+// Every file has to hold exactly one node. Failures are reported in Result.Err and do not stop the batch.
+//
+// Each of the workers reuses a single Decoder and read buffer, and at most workers results are buffered,
+// so the memory use does not grow with the number of files. Results are sent in the order they complete.
+// The returned channel is closed once paths is closed and drained, or ctx is done.
+// Non-positive workers default to the number of CPUs.
+//
+// configure, unless nil, is called with the Decoder of every worker before its first file,
+// to set options such as PreserveOrder or MaxStringLength. It could be called from several goroutines at once.
+func DecodeFiles(ctx context.Context, paths <-chan string, workers int, configure func(*Decoder)) <-chan Result {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make(chan Result, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			decodeFilesWorker(ctx, paths, results, configure)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func decodeFilesWorker(ctx context.Context, paths <-chan string, results chan<- Result, configure func(*Decoder)) {
+	reader := bufio.NewReader(nil)
+	decoder := NewDecoder(reader)
+	if configure != nil {
+		configure(decoder)
+	}
+	for {
+		var path string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case path, ok = <-paths:
+			if !ok {
+				return
+			}
+		}
+
+		node, err := decodeFile(path, reader, decoder)
+		select {
+		case <-ctx.Done():
+			return
+		case results <- Result{Path: path, Node: node, Err: err}:
+		}
+	}
+}
+
+func decodeFile(path string, reader *bufio.Reader, decoder *Decoder) (BnCode, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BnCode{}, err
+	}
+	defer f.Close()
+
+	reader.Reset(f)
+	defer reader.Reset(nil)
+	decoder.Reset(reader)
+	node, err := decoder.Decode()
+	if err != nil {
+		return BnCode{}, err
+	}
+	switch _, err = reader.ReadByte(); err {
+	case io.EOF:
+	case nil:
+		return BnCode{}, fmt.Errorf("Unexpected trailing data after the root node")
+	default:
+		return BnCode{}, err
+	}
+	return node, nil
+}
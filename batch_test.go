@@ -0,0 +1,116 @@
+package bencode
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bencode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"valid.torrent":    "d4:name1:ae",
+		"invalid.torrent":  "d4:name",
+		"trailing.torrent": "i1ei2e",
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "valid.torrent", wantErr: false},
+		{name: "invalid.torrent", wantErr: true},
+		{name: "trailing.torrent", wantErr: true},
+		{name: "missing.torrent", wantErr: true},
+	}
+	paths := make(chan string)
+	go func() {
+		for i := 0; i < 100; i++ {
+			for _, tt := range tests {
+				paths <- filepath.Join(dir, tt.name)
+			}
+		}
+		close(paths)
+	}()
+
+	errs := make(map[string]int)
+	count := 0
+	for r := range DecodeFiles(context.Background(), paths, 4, nil) {
+		count++
+		if r.Err != nil {
+			errs[filepath.Base(r.Path)]++
+			continue
+		}
+		if name, _ := r.Node.GetDict(); name["name"].Value != "a" {
+			t.Errorf("DecodeFiles() = %v for %s", r.Node, r.Path)
+		}
+	}
+	if count != 100*len(tests) {
+		t.Errorf("DecodeFiles() returned %d results, want %d", count, 100*len(tests))
+	}
+	for _, tt := range tests {
+		if got := errs[tt.name] == 100; got != tt.wantErr {
+			t.Errorf("DecodeFiles() failed %d times for %s, wantErr %v", errs[tt.name], tt.name, tt.wantErr)
+		}
+	}
+}
+
+func TestDecodeFiles_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	paths := make(chan string)
+	results := DecodeFiles(ctx, paths, 0, nil)
+	cancel()
+	for r := range results {
+		t.Errorf("DecodeFiles() returned %v after cancel", r)
+	}
+}
+
+func TestDecodeFiles_Configure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bencode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"unordered.torrent": "d1:b1:x1:a1:ye",
+		"long.torrent":      "d4:name10:0123456789e",
+	}
+	paths := make(chan string, len(files))
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths <- filepath.Join(dir, name)
+	}
+	close(paths)
+
+	configure := func(d *Decoder) {
+		d.PreserveOrder()
+		d.MaxStringLength(8)
+	}
+	for r := range DecodeFiles(context.Background(), paths, 2, configure) {
+		switch filepath.Base(r.Path) {
+		case "unordered.torrent":
+			if dict, ok := r.Node.Value.(OrderedDict); r.Err != nil || !ok || dict[0].Key != "b" {
+				t.Errorf("DecodeFiles() = %v, %v, want the keys in their original order", r.Node, r.Err)
+			}
+		case "long.torrent":
+			if r.Err == nil {
+				t.Errorf("DecodeFiles() decoded a string over MaxStringLength")
+			}
+		}
+	}
+}
@@ -1,20 +1,39 @@
 package bencode
 
 import (
-	"errors"
+	"strconv"
 )
 
+// State indicates the type of the Value of BnCode.
+type State int
+
 const (
 	// BnInt enum that indicates the state of the Value of BnCode
-	BnInt = iota
+	BnInt State = iota
 	// BnString enum that indicates the state of the Value of BnCode
-	BnString = iota
+	BnString
 	// BnList enum that indicates the state of the Value of BnCode
-	BnList = iota
+	BnList
 	// BnDict enum that indicates the state of the Value of BnCode
-	BnDict = iota
+	BnDict
 )
 
+// String returns the name of the state: int, string, list or dict.
+func (s State) String() string {
+	switch s {
+	case BnInt:
+		return "int"
+	case BnString:
+		return "string"
+	case BnList:
+		return "list"
+	case BnDict:
+		return "dict"
+	default:
+		return "State(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
 // BnCode is structure that wraps main Bencode types :
 //
 // 1. int - constant BnInt
@@ -28,7 +47,7 @@ const (
 // Each of the types have corresponding code that will show the current Value state,
 // hence you can only call geInt method on the BnCode, which State is set to BnInt.
 type BnCode struct {
-	State int
+	State State
 	Value interface{}
 }
 
@@ -36,43 +55,67 @@ type BnCode struct {
 //
 // Returns error if unable to cast to int or State is not BnInt.
 func (obj *BnCode) GetInt() (int, error) {
-	if val, ok := obj.Value.(int); obj.State != BnInt || !ok {
-		return val, errors.New("Given Value is not an int")
-	} else {
-		return val, nil
-	}
+	return As[int](*obj)
 }
 
 // GetString tries converting Value to string.
 //
 // Returns error if unable to cast to string or State is not BnString.
 func (obj *BnCode) GetString() (string, error) {
-	if val, ok := obj.Value.(string); obj.State != BnString || !ok {
-		return val, errors.New("Given Value is not an string")
-	} else {
-		return val, nil
+	return As[string](*obj)
+}
+
+// DictEntry is a single key value pair of OrderedDict.
+type DictEntry struct {
+	Key   string
+	Value BnCode
+}
+
+// OrderedDict is an alternative Value of the BnDict node, which keeps the entries
+// in the order they were seen and allows duplicate keys.
+//
+// Decoder produces it when PreserveOrder is set, Encode writes it back as is, without sorting.
+type OrderedDict []DictEntry
+
+// Get returns the value of the last entry with the given key.
+func (d OrderedDict) Get(key string) (BnCode, bool) {
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i].Key == key {
+			return d[i].Value, true
+		}
 	}
+	return BnCode{}, false
+}
+
+// Map converts the entries to a regular dictionary, later duplicates override earlier ones.
+func (d OrderedDict) Map() map[string]BnCode {
+	rc := make(map[string]BnCode, len(d))
+	for _, e := range d {
+		rc[e.Key] = e.Value
+	}
+	return rc
 }
 
 // GetDict tries converting Value to dictionary
 //
+// OrderedDict values are converted with OrderedDict.Map, so changes to the returned
+// dictionary are not reflected in the node.
+//
 // Returns error if unable to cast to dictionary or State is not BnDict
 func (obj *BnCode) GetDict() (map[string]BnCode, error) {
-	if val, ok := obj.Value.(map[string]BnCode); obj.State != BnDict || !ok {
-		return val, errors.New("Given Value is not a dictionary")
-	} else {
-		return val, nil
-	}
+	return As[map[string]BnCode](*obj)
+}
+
+// GetOrderedDict tries converting Value to OrderedDict
+//
+// Returns error if unable to cast to OrderedDict or State is not BnDict
+func (obj *BnCode) GetOrderedDict() (OrderedDict, error) {
+	return As[OrderedDict](*obj)
 }
 
 // GetList tries converting Value to list
 //
 // Returns error if unable to cast to list or State is not BnList
 func (obj *BnCode) GetList() ([]BnCode, error) {
-
-	if val, ok := obj.Value.([]BnCode); obj.State != BnList || !ok {
-		return val, errors.New("Given Value is not a list")
-	} else {
-		return val, nil
-	}
+	return As[[]BnCode](*obj)
 }
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// importPath is the import path of the bencode package used by the generated code.
+const importPath = "bencode"
+
+type generator struct {
+	buf        bytes.Buffer
+	useStrconv bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// errorf returns the source of an fmt.Errorf call, which prefixes the message with the key.
+func errorf(key string, format string, args ...string) string {
+	quoted := strconv.Quote(strings.ReplaceAll(key, "%", "%%") + ": " + format)
+	return fmt.Sprintf("fmt.Errorf(%s)", strings.Join(append([]string{quoted}, args...), ", "))
+}
+
+// generate returns the formatted source of the methods of the given types.
+func generate(pkg *pkgInfo, typeNames []string) ([]byte, error) {
+	for _, name := range typeNames {
+		pkg.generated[name] = true
+	}
+
+	var body generator
+	for _, name := range typeNames {
+		fields, err := pkg.structFields(name)
+		if err != nil {
+			return nil, err
+		}
+		body.typeMethods(name, fields)
+	}
+
+	var g generator
+	g.printf("// Code generated by bencodegen; DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg.name)
+	g.printf("import (\n\"fmt\"\n")
+	if body.useStrconv {
+		g.printf("\"strconv\"\n")
+	}
+	g.printf("\n%q\n)\n", importPath)
+	g.buf.Write(body.buf.Bytes())
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Generated invalid code: %v", err)
+	}
+	return src, nil
+}
+
+func (g *generator) typeMethods(name string, fields []field) {
+	var enc generator
+	needsErr := false
+	for _, f := range fields {
+		v := "x." + f.goName
+		cond := ""
+		switch {
+		case f.typ.kind == kindPtr:
+			cond = v + " != nil"
+		case !f.omitEmpty:
+		case f.typ.kind == kindString || f.typ.kind == kindBytes || f.typ.kind == kindSlice:
+			cond = "len(" + v + ") != 0"
+		case f.typ.kind == kindInt || f.typ.kind == kindUint:
+			cond = v + " != 0"
+		case f.typ.kind == kindBool:
+			cond = v
+		}
+		if cond != "" {
+			enc.printf("if %s {\n", cond)
+		}
+		enc.printf("dst = append(dst, %s...)\n", strconv.Quote(strconv.Itoa(len(f.key))+":"+f.key))
+		if f.typ.kind == kindPtr {
+			needsErr = enc.encode(f.typ.elem, v, f.key, 0) || needsErr
+		} else {
+			needsErr = enc.encode(f.typ, v, f.key, 0) || needsErr
+		}
+		if cond != "" {
+			enc.printf("}\n")
+		}
+	}
+	g.useStrconv = g.useStrconv || enc.useStrconv
+
+	g.printf("\n// AppendBencode appends the bencoded %s to dst.\n", name)
+	g.printf("func (x *%s) AppendBencode(dst []byte) ([]byte, error) {\n", name)
+	if needsErr {
+		g.printf("var err error\n")
+	}
+	g.printf("dst = append(dst, 'd')\n")
+	g.buf.Write(enc.buf.Bytes())
+	g.printf("return append(dst, 'e'), nil\n}\n")
+
+	g.printf("\n// MarshalBencode implements bencode.Marshaler.\n")
+	g.printf("func (x *%s) MarshalBencode() ([]byte, error) {\nreturn x.AppendBencode(nil)\n}\n", name)
+
+	g.printf("\n// UnmarshalBencode implements bencode.Unmarshaler.\n")
+	g.printf("func (x *%s) UnmarshalBencode(data []byte) error {\n", name)
+	g.printf("s := bencode.NewScanner(data)\nif err := x.scanBencode(s); err != nil {\nreturn err\n}\nreturn s.Done()\n}\n")
+
+	g.printf("\n// scanBencode reads the %s at the position of the scanner.\n", name)
+	g.printf("func (x *%s) scanBencode(s *bencode.Scanner) error {\n", name)
+	g.printf("if err := s.Dict(); err != nil {\nreturn err\n}\n")
+	g.printf("for s.More() {\nkey, err := s.Key()\nif err != nil {\nreturn err\n}\nswitch string(key) {\n")
+	for _, f := range fields {
+		g.printf("case %s:\n", strconv.Quote(f.key))
+		g.decode(f.typ, "x."+f.goName, f.key, 0)
+	}
+	g.printf("default:\nif _, err = s.Raw(); err != nil {\nreturn err\n}\n")
+	g.printf("}\n}\nreturn s.End()\n}\n")
+}
+
+// encode writes the code appending the value v of type t, it reports whether the code uses the err variable.
+func (g *generator) encode(t *fieldType, v string, key string, depth int) bool {
+	switch t.kind {
+	case kindString, kindBytes, kindArray:
+		g.useStrconv = true
+		if t.kind == kindArray {
+			v += "[:]"
+		}
+		g.printf("dst = strconv.AppendInt(dst, int64(len(%s)), 10)\n", v)
+		g.printf("dst = append(dst, ':')\n")
+		g.printf("dst = append(dst, %s...)\n", v)
+	case kindInt:
+		g.useStrconv = true
+		g.printf("dst = append(dst, 'i')\ndst = strconv.AppendInt(dst, int64(%s), 10)\ndst = append(dst, 'e')\n", v)
+	case kindUint:
+		g.useStrconv = true
+		g.printf("dst = append(dst, 'i')\ndst = strconv.AppendUint(dst, uint64(%s), 10)\ndst = append(dst, 'e')\n", v)
+	case kindBool:
+		g.printf("if %s {\ndst = append(dst, \"i1e\"...)\n} else {\ndst = append(dst, \"i0e\"...)\n}\n", v)
+	case kindStruct:
+		g.printf("if dst, err = %s.AppendBencode(dst); err != nil {\nreturn dst, %s\n}\n", v, errorf(key, "%v", "err"))
+		return true
+	case kindMarshaler:
+		g.printf("{\nb, err := %s.MarshalBencode()\nif err != nil {\nreturn dst, %s\n}\ndst = append(dst, b...)\n}\n", v, errorf(key, "%v", "err"))
+	case kindPtr:
+		g.printf("if %s == nil {\nreturn dst, %s\n}\n", v, errorf(key, "Can not marshal nil "+t.name))
+		return g.encode(t.elem, v, key, depth)
+	case kindSlice:
+		i := fmt.Sprintf("i%d", depth)
+		g.printf("dst = append(dst, 'l')\nfor %s := range %s {\n", i, v)
+		usesErr := g.encode(t.elem, v+"["+i+"]", key, depth+1)
+		g.printf("}\ndst = append(dst, 'e')\n")
+		return usesErr
+	}
+	return false
+}
+
+// overflowCheck returns the condition that tells the decoded int n does not fit into t.
+func overflowCheck(t *fieldType) string {
+	switch t.basic {
+	case "int", "int64":
+		return ""
+	case "uint", "uint64", "uintptr":
+		return "n < 0"
+	}
+	if t.kind == kindUint || t.basic == "byte" {
+		return fmt.Sprintf("n < 0 || int(%s(n)) != n", t.name)
+	}
+	return fmt.Sprintf("int(%s(n)) != n", t.name)
+}
+
+// convert returns the expression converting expr to t, unless t is the type of expr.
+func convert(t *fieldType, expr string, exprType string) string {
+	if t.name == exprType {
+		return expr
+	}
+	return t.name + "(" + expr + ")"
+}
+
+// decode writes the code reading the next value of the scanner s into target.
+func (g *generator) decode(t *fieldType, target string, key string, depth int) {
+	switch t.kind {
+	case kindString:
+		g.printf("str, err := s.Str()\nif err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+		g.printf("%s = %s\n", target, convert(t, "str", "string"))
+	case kindBytes:
+		g.printf("b, err := s.Bytes()\nif err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+		g.printf("%s = append(%s{}, b...)\n", target, t.name)
+	case kindArray:
+		g.printf("b, err := s.Bytes()\nif err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+		g.printf("if len(b) != len(%s) {\nreturn %s\n}\n", target, errorf(key, "String of %d bytes does not fit into "+t.name, "len(b)"))
+		g.printf("copy(%s[:], b)\n", target)
+	case kindInt, kindUint:
+		g.printf("n, err := s.Int()\nif err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+		if check := overflowCheck(t); check != "" {
+			g.printf("if %s {\nreturn %s\n}\n", check, errorf(key, "Value %d overflows "+t.name, "n"))
+		}
+		g.printf("%s = %s\n", target, convert(t, "n", "int"))
+	case kindBool:
+		g.printf("n, err := s.Int()\nif err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+		g.printf("%s = %s\n", target, convert(t, "n != 0", "bool"))
+	case kindStruct:
+		g.printf("if err := %s.scanBencode(s); err != nil {\nreturn %s\n}\n", target, errorf(key, "%v", "err"))
+	case kindMarshaler:
+		g.printf("raw, err := s.Raw()\nif err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+		g.printf("if err := %s.UnmarshalBencode(raw); err != nil {\nreturn %s\n}\n", target, errorf(key, "%v", "err"))
+	case kindPtr:
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", target, target, t.elem.name)
+		g.decode(t.elem, target, key, depth)
+	case kindSlice:
+		elem := fmt.Sprintf("e%d", depth)
+		g.printf("if err := s.List(); err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+		g.printf("%s = %s{}\nfor s.More() {\nvar %s %s\n", target, t.name, elem, t.elem.name)
+		g.decode(t.elem, elem, key, depth+1)
+		g.printf("%s = append(%s, %s)\n}\n", target, target, elem)
+		g.printf("if err := s.End(); err != nil {\nreturn %s\n}\n", errorf(key, "%v", "err"))
+	}
+}
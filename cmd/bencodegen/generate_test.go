@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate_Sample(t *testing.T) {
+	pkg, err := loadPackage("internal/sample", "sample_bencode.go")
+	if err != nil {
+		t.Fatalf("loadPackage() error = %v", err)
+	}
+	got, err := generate(pkg, []string{"Query", "Args"})
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	want, err := ioutil.ReadFile("internal/sample/sample_bencode.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("generate() differs from internal/sample/sample_bencode.go, run go generate")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{
+			name:    "Positive test case",
+			src:     "type T struct {\nA string `bencode:\"a\"`\nB, C int\nd bool\n}",
+			wantErr: false,
+		},
+		{
+			name:    "Empty struct",
+			src:     "type T struct{}",
+			wantErr: false,
+		},
+		{
+			name:    "Not a struct",
+			src:     "type T []int",
+			wantErr: true,
+		},
+		{
+			name:    "Map field",
+			src:     "type T struct {\nA map[string]int\n}",
+			wantErr: true,
+		},
+		{
+			name:    "Embedded field",
+			src:     "type E struct{}\ntype T struct {\nE\n}",
+			wantErr: true,
+		},
+		{
+			name:    "Duplicate key",
+			src:     "type T struct {\nA int `bencode:\"B\"`\nB int\n}",
+			wantErr: true,
+		},
+		{
+			name:    "Struct without methods",
+			src:     "type E struct{}\ntype T struct {\nA E\n}",
+			wantErr: true,
+		},
+		{
+			name:    "Pointer to int",
+			src:     "type T struct {\nA *int\n}",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown type",
+			src:     "type T struct {\nA float64\n}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "bencodegen")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err = ioutil.WriteFile(filepath.Join(dir, "types.go"), []byte("package p\n\n"+tt.src+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pkg, err := loadPackage(dir, "types_bencode.go")
+			if err != nil {
+				t.Fatalf("loadPackage() error = %v", err)
+			}
+			if _, err = generate(pkg, []string{"T"}); (err != nil) != tt.wantErr {
+				t.Errorf("generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Package sample holds DHT-like messages with generated bencode methods, it is used to test bencodegen.
+package sample
+
+import (
+	"errors"
+
+	"bencode"
+)
+
+//go:generate go run bencode/cmd/bencodegen -type Query,Args
+
+type NodeID [20]byte
+
+type Method string
+
+type Port uint16
+
+// Version has its own wire format, a list of two ints
+type Version struct {
+	Major, Minor int
+}
+
+func (v *Version) MarshalBencode() ([]byte, error) {
+	return bencode.Marshal([]int{v.Major, v.Minor})
+}
+
+func (v *Version) UnmarshalBencode(data []byte) error {
+	var parts []int
+	if err := bencode.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	if len(parts) != 2 {
+		return errors.New("Invalid version")
+	}
+	v.Major, v.Minor = parts[0], parts[1]
+	return nil
+}
+
+type Args struct {
+	ID          NodeID `bencode:"id"`
+	Port        Port   `bencode:"port,omitempty"`
+	ImpliedPort bool   `bencode:"implied_port,omitempty"`
+	Token       []byte `bencode:"token,omitempty"`
+	Targets     []NodeID
+}
+
+type Query struct {
+	TransactionID string   `bencode:"t"`
+	Type          string   `bencode:"y"`
+	Method        Method   `bencode:"q"`
+	Args          *Args    `bencode:"a"`
+	Version       *Version `bencode:"v"`
+	Want          []string `bencode:"want,omitempty"`
+	Seqs          [][]int8 `bencode:"seqs,omitempty"`
+	Ignored       string   `bencode:"-"`
+	internal      int
+}
@@ -0,0 +1,309 @@
+// Code generated by bencodegen; DO NOT EDIT.
+
+package sample
+
+import (
+	"fmt"
+	"strconv"
+
+	"bencode"
+)
+
+// AppendBencode appends the bencoded Query to dst.
+func (x *Query) AppendBencode(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, 'd')
+	if x.Args != nil {
+		dst = append(dst, "1:a"...)
+		if dst, err = x.Args.AppendBencode(dst); err != nil {
+			return dst, fmt.Errorf("a: %v", err)
+		}
+	}
+	dst = append(dst, "1:q"...)
+	dst = strconv.AppendInt(dst, int64(len(x.Method)), 10)
+	dst = append(dst, ':')
+	dst = append(dst, x.Method...)
+	if len(x.Seqs) != 0 {
+		dst = append(dst, "4:seqs"...)
+		dst = append(dst, 'l')
+		for i0 := range x.Seqs {
+			dst = append(dst, 'l')
+			for i1 := range x.Seqs[i0] {
+				dst = append(dst, 'i')
+				dst = strconv.AppendInt(dst, int64(x.Seqs[i0][i1]), 10)
+				dst = append(dst, 'e')
+			}
+			dst = append(dst, 'e')
+		}
+		dst = append(dst, 'e')
+	}
+	dst = append(dst, "1:t"...)
+	dst = strconv.AppendInt(dst, int64(len(x.TransactionID)), 10)
+	dst = append(dst, ':')
+	dst = append(dst, x.TransactionID...)
+	if x.Version != nil {
+		dst = append(dst, "1:v"...)
+		{
+			b, err := x.Version.MarshalBencode()
+			if err != nil {
+				return dst, fmt.Errorf("v: %v", err)
+			}
+			dst = append(dst, b...)
+		}
+	}
+	if len(x.Want) != 0 {
+		dst = append(dst, "4:want"...)
+		dst = append(dst, 'l')
+		for i0 := range x.Want {
+			dst = strconv.AppendInt(dst, int64(len(x.Want[i0])), 10)
+			dst = append(dst, ':')
+			dst = append(dst, x.Want[i0]...)
+		}
+		dst = append(dst, 'e')
+	}
+	dst = append(dst, "1:y"...)
+	dst = strconv.AppendInt(dst, int64(len(x.Type)), 10)
+	dst = append(dst, ':')
+	dst = append(dst, x.Type...)
+	return append(dst, 'e'), nil
+}
+
+// MarshalBencode implements bencode.Marshaler.
+func (x *Query) MarshalBencode() ([]byte, error) {
+	return x.AppendBencode(nil)
+}
+
+// UnmarshalBencode implements bencode.Unmarshaler.
+func (x *Query) UnmarshalBencode(data []byte) error {
+	s := bencode.NewScanner(data)
+	if err := x.scanBencode(s); err != nil {
+		return err
+	}
+	return s.Done()
+}
+
+// scanBencode reads the Query at the position of the scanner.
+func (x *Query) scanBencode(s *bencode.Scanner) error {
+	if err := s.Dict(); err != nil {
+		return err
+	}
+	for s.More() {
+		key, err := s.Key()
+		if err != nil {
+			return err
+		}
+		switch string(key) {
+		case "a":
+			if x.Args == nil {
+				x.Args = new(Args)
+			}
+			if err := x.Args.scanBencode(s); err != nil {
+				return fmt.Errorf("a: %v", err)
+			}
+		case "q":
+			str, err := s.Str()
+			if err != nil {
+				return fmt.Errorf("q: %v", err)
+			}
+			x.Method = Method(str)
+		case "seqs":
+			if err := s.List(); err != nil {
+				return fmt.Errorf("seqs: %v", err)
+			}
+			x.Seqs = [][]int8{}
+			for s.More() {
+				var e0 []int8
+				if err := s.List(); err != nil {
+					return fmt.Errorf("seqs: %v", err)
+				}
+				e0 = []int8{}
+				for s.More() {
+					var e1 int8
+					n, err := s.Int()
+					if err != nil {
+						return fmt.Errorf("seqs: %v", err)
+					}
+					if int(int8(n)) != n {
+						return fmt.Errorf("seqs: Value %d overflows int8", n)
+					}
+					e1 = int8(n)
+					e0 = append(e0, e1)
+				}
+				if err := s.End(); err != nil {
+					return fmt.Errorf("seqs: %v", err)
+				}
+				x.Seqs = append(x.Seqs, e0)
+			}
+			if err := s.End(); err != nil {
+				return fmt.Errorf("seqs: %v", err)
+			}
+		case "t":
+			str, err := s.Str()
+			if err != nil {
+				return fmt.Errorf("t: %v", err)
+			}
+			x.TransactionID = str
+		case "v":
+			if x.Version == nil {
+				x.Version = new(Version)
+			}
+			raw, err := s.Raw()
+			if err != nil {
+				return fmt.Errorf("v: %v", err)
+			}
+			if err := x.Version.UnmarshalBencode(raw); err != nil {
+				return fmt.Errorf("v: %v", err)
+			}
+		case "want":
+			if err := s.List(); err != nil {
+				return fmt.Errorf("want: %v", err)
+			}
+			x.Want = []string{}
+			for s.More() {
+				var e0 string
+				str, err := s.Str()
+				if err != nil {
+					return fmt.Errorf("want: %v", err)
+				}
+				e0 = str
+				x.Want = append(x.Want, e0)
+			}
+			if err := s.End(); err != nil {
+				return fmt.Errorf("want: %v", err)
+			}
+		case "y":
+			str, err := s.Str()
+			if err != nil {
+				return fmt.Errorf("y: %v", err)
+			}
+			x.Type = str
+		default:
+			if _, err = s.Raw(); err != nil {
+				return err
+			}
+		}
+	}
+	return s.End()
+}
+
+// AppendBencode appends the bencoded Args to dst.
+func (x *Args) AppendBencode(dst []byte) ([]byte, error) {
+	dst = append(dst, 'd')
+	dst = append(dst, "7:Targets"...)
+	dst = append(dst, 'l')
+	for i0 := range x.Targets {
+		dst = strconv.AppendInt(dst, int64(len(x.Targets[i0][:])), 10)
+		dst = append(dst, ':')
+		dst = append(dst, x.Targets[i0][:]...)
+	}
+	dst = append(dst, 'e')
+	dst = append(dst, "2:id"...)
+	dst = strconv.AppendInt(dst, int64(len(x.ID[:])), 10)
+	dst = append(dst, ':')
+	dst = append(dst, x.ID[:]...)
+	if x.ImpliedPort {
+		dst = append(dst, "12:implied_port"...)
+		if x.ImpliedPort {
+			dst = append(dst, "i1e"...)
+		} else {
+			dst = append(dst, "i0e"...)
+		}
+	}
+	if x.Port != 0 {
+		dst = append(dst, "4:port"...)
+		dst = append(dst, 'i')
+		dst = strconv.AppendUint(dst, uint64(x.Port), 10)
+		dst = append(dst, 'e')
+	}
+	if len(x.Token) != 0 {
+		dst = append(dst, "5:token"...)
+		dst = strconv.AppendInt(dst, int64(len(x.Token)), 10)
+		dst = append(dst, ':')
+		dst = append(dst, x.Token...)
+	}
+	return append(dst, 'e'), nil
+}
+
+// MarshalBencode implements bencode.Marshaler.
+func (x *Args) MarshalBencode() ([]byte, error) {
+	return x.AppendBencode(nil)
+}
+
+// UnmarshalBencode implements bencode.Unmarshaler.
+func (x *Args) UnmarshalBencode(data []byte) error {
+	s := bencode.NewScanner(data)
+	if err := x.scanBencode(s); err != nil {
+		return err
+	}
+	return s.Done()
+}
+
+// scanBencode reads the Args at the position of the scanner.
+func (x *Args) scanBencode(s *bencode.Scanner) error {
+	if err := s.Dict(); err != nil {
+		return err
+	}
+	for s.More() {
+		key, err := s.Key()
+		if err != nil {
+			return err
+		}
+		switch string(key) {
+		case "Targets":
+			if err := s.List(); err != nil {
+				return fmt.Errorf("Targets: %v", err)
+			}
+			x.Targets = []NodeID{}
+			for s.More() {
+				var e0 NodeID
+				b, err := s.Bytes()
+				if err != nil {
+					return fmt.Errorf("Targets: %v", err)
+				}
+				if len(b) != len(e0) {
+					return fmt.Errorf("Targets: String of %d bytes does not fit into NodeID", len(b))
+				}
+				copy(e0[:], b)
+				x.Targets = append(x.Targets, e0)
+			}
+			if err := s.End(); err != nil {
+				return fmt.Errorf("Targets: %v", err)
+			}
+		case "id":
+			b, err := s.Bytes()
+			if err != nil {
+				return fmt.Errorf("id: %v", err)
+			}
+			if len(b) != len(x.ID) {
+				return fmt.Errorf("id: String of %d bytes does not fit into NodeID", len(b))
+			}
+			copy(x.ID[:], b)
+		case "implied_port":
+			n, err := s.Int()
+			if err != nil {
+				return fmt.Errorf("implied_port: %v", err)
+			}
+			x.ImpliedPort = n != 0
+		case "port":
+			n, err := s.Int()
+			if err != nil {
+				return fmt.Errorf("port: %v", err)
+			}
+			if n < 0 || int(Port(n)) != n {
+				return fmt.Errorf("port: Value %d overflows Port", n)
+			}
+			x.Port = Port(n)
+		case "token":
+			b, err := s.Bytes()
+			if err != nil {
+				return fmt.Errorf("token: %v", err)
+			}
+			x.Token = append([]byte{}, b...)
+		default:
+			if _, err = s.Raw(); err != nil {
+				return err
+			}
+		}
+	}
+	return s.End()
+}
@@ -0,0 +1,118 @@
+package sample
+
+import (
+	"reflect"
+	"testing"
+
+	"bencode"
+)
+
+func sampleQuery() Query {
+	return Query{
+		TransactionID: "aa",
+		Type:          "q",
+		Method:        "get_peers",
+		Args: &Args{
+			ID:      NodeID{'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9'},
+			Port:    6881,
+			Targets: []NodeID{},
+		},
+		Version: &Version{Major: 1, Minor: 2},
+		Want:    []string{"n4", "n6"},
+		Seqs:    [][]int8{{-1, 2}, {}},
+	}
+}
+
+const sampleData = "d1:ad7:Targetsle2:id20:abcdefghij01234567894:porti6881ee1:q9:get_peers4:seqslli-1ei2eelee1:t2:aa1:vli1ei2ee4:wantl2:n42:n6e1:y1:qe"
+
+func TestQuery_MarshalBencode(t *testing.T) {
+	q := sampleQuery()
+	got, err := q.MarshalBencode()
+	if err != nil {
+		t.Fatalf("MarshalBencode() error = %v", err)
+	}
+	if string(got) != sampleData {
+		t.Errorf("MarshalBencode() = %s, want %s", got, sampleData)
+	}
+}
+
+func TestQuery_UnmarshalBencode(t *testing.T) {
+	var got Query
+	if err := got.UnmarshalBencode([]byte(sampleData)); err != nil {
+		t.Fatalf("UnmarshalBencode() error = %v", err)
+	}
+	if want := sampleQuery(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalBencode() = %+v, want %+v", got, want)
+	}
+
+	// the generated methods are used by the reflection based functions as well
+	var nested struct {
+		Query Query `bencode:"query"`
+	}
+	if err := bencode.Unmarshal([]byte("d5:query"+sampleData+"e"), &nested); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(nested.Query, got) {
+		t.Errorf("Unmarshal() = %+v, want %+v", nested.Query, got)
+	}
+}
+
+func TestArgs_UnmarshalBencode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "Unknown keys", data: "d2:id20:abcdefghij01234567895:otheri1ee", wantErr: false},
+		{name: "Short id", data: "d2:id3:abce", wantErr: true},
+		{name: "Port overflow", data: "d4:porti70000ee", wantErr: true},
+		{name: "Negative port", data: "d4:porti-1ee", wantErr: true},
+		{name: "Targets is not a list", data: "d7:Targets3:abce", wantErr: true},
+		{name: "Not a dictionary", data: "li1ee", wantErr: true},
+		{name: "Invalid data", data: "d2:id", wantErr: true},
+		{name: "Invalid unknown key", data: "d5:otherli01eee", wantErr: true},
+		{name: "Unsorted keys", data: "d4:porti1e2:id20:abcdefghij0123456789e", wantErr: true},
+		{name: "Trailing data", data: "d4:porti1eei1e", wantErr: true},
+		{name: "Unterminated targets", data: "d7:Targetsl", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a Args
+			if err := a.UnmarshalBencode([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalBencode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuery_AppendBencodeAllocs(t *testing.T) {
+	q := sampleQuery()
+	q.Version = nil
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := q.AppendBencode(buf[:0]); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("AppendBencode() made %v allocations, want 0", allocs)
+	}
+}
+
+func BenchmarkQuery_AppendBencode(b *testing.B) {
+	q := sampleQuery()
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = q.AppendBencode(buf[:0])
+	}
+}
+
+func BenchmarkQuery_Marshal(b *testing.B) {
+	type reflected Query
+	q := reflected(sampleQuery())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = bencode.Marshal(q)
+	}
+}
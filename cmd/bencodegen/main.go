@@ -0,0 +1,71 @@
+// Command bencodegen generates reflection free MarshalBencode and UnmarshalBencode methods
+// for struct types with "bencode" tags.
+//
+// It is meant to be run by go generate, for example
+//
+//	//go:generate go run bencode/cmd/bencodegen -type Query,Response
+//
+// writes the methods of the listed types of the package into <file>_bencode.go, where <file> is
+// the name of the file holding the directive.
+//
+// Fields follow the same tag rules as bencode.Marshal: the key is the tag name or the field name,
+// "-" skips the field and "omitempty" skips zero values. Supported field types are strings, byte slices,
+// byte arrays, integers, booleans, slices of supported types, other generated types and pointers to them.
+// Types of the package that implement Marshaler and Unmarshaler, as well as all the types of other
+// packages, are encoded with their own methods. Named types are resolved to their underlying types.
+//
+// Dictionary keys are sorted at generation time, so the generated code appends the encoding directly
+// to the buffer. Decoding walks the index built by bencode.Parse without reflection.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated list of type names, required")
+	output := flag.String("output", "", "output file name, defaults to <file>_bencode.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: bencodegen -type T1,T2 [-output file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if *output == "" {
+		base := "types"
+		if goFile := os.Getenv("GOFILE"); goFile != "" {
+			base = strings.TrimSuffix(goFile, ".go")
+		}
+		*output = filepath.Join(dir, base+"_bencode.go")
+	}
+
+	if err := run(dir, strings.Split(*typeNames, ","), *output); err != nil {
+		fmt.Fprintf(os.Stderr, "bencodegen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, typeNames []string, output string) error {
+	pkg, err := loadPackage(dir, filepath.Base(output))
+	if err != nil {
+		return err
+	}
+	src, err := generate(pkg, typeNames)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, src, 0644)
+}
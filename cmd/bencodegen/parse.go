@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pkgInfo holds the declarations of the package the code is generated for.
+type pkgInfo struct {
+	name string
+	// specs are the type declarations by name
+	specs map[string]*ast.TypeSpec
+	// marshalers are the types that have a MarshalBencode method
+	marshalers map[string]bool
+	// generated are the types the code is generated for
+	generated map[string]bool
+}
+
+// loadPackage parses the non-test files of the package in dir, except the previous output.
+func loadPackage(dir string, output string) (*pkgInfo, error) {
+	fset := token.NewFileSet()
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}
+	pkgs, err := parser.ParseDir(fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("Expected a single package in %s, found %d", dir, len(pkgs))
+	}
+
+	info := &pkgInfo{specs: make(map[string]*ast.TypeSpec), marshalers: make(map[string]bool), generated: make(map[string]bool)}
+	for name, pkg := range pkgs {
+		info.name = name
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				switch decl := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							info.specs[ts.Name.Name] = ts
+						}
+					}
+				case *ast.FuncDecl:
+					if decl.Recv != nil && decl.Name.Name == "MarshalBencode" {
+						info.marshalers[receiverName(decl.Recv.List[0].Type)] = true
+					}
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+func receiverName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindBytes
+	kindArray
+	kindInt
+	kindUint
+	kindBool
+	// kindStruct is a generated type
+	kindStruct
+	kindMarshaler
+	kindSlice
+	kindPtr
+)
+
+// fieldType is the resolved type of a field.
+type fieldType struct {
+	kind kind
+	// name is the Go type expression as written in the source
+	name string
+	// basic is the name of the underlying type of integers
+	basic string
+	// elem is the element type of slices and pointers
+	elem *fieldType
+}
+
+var basicKinds = map[string]kind{
+	"string": kindString,
+	"bool":   kindBool,
+	"int":    kindInt, "int8": kindInt, "int16": kindInt, "int32": kindInt, "int64": kindInt, "rune": kindInt,
+	"uint": kindUint, "uint8": kindUint, "uint16": kindUint, "uint32": kindUint, "uint64": kindUint, "uintptr": kindUint, "byte": kindUint,
+}
+
+func isByte(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && (ident.Name == "byte" || ident.Name == "uint8")
+}
+
+// resolve maps the type expression to the way it is encoded.
+func (p *pkgInfo) resolve(expr ast.Expr) (*fieldType, error) {
+	name := types.ExprString(expr)
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if k, ok := basicKinds[expr.Name]; ok {
+			return &fieldType{kind: k, name: name, basic: name}, nil
+		}
+		spec, ok := p.specs[expr.Name]
+		switch {
+		case p.generated[expr.Name]:
+			return &fieldType{kind: kindStruct, name: name}, nil
+		case p.marshalers[expr.Name]:
+			return &fieldType{kind: kindMarshaler, name: name}, nil
+		case !ok:
+			return nil, fmt.Errorf("Unknown type %s", name)
+		}
+		if _, ok := spec.Type.(*ast.StructType); ok {
+			return nil, fmt.Errorf("Type %s is neither generated nor implements Marshaler", name)
+		}
+		t, err := p.resolve(spec.Type)
+		if err != nil {
+			return nil, err
+		}
+		t.name = name
+		return t, nil
+	case *ast.ArrayType:
+		switch {
+		case expr.Len == nil && isByte(expr.Elt):
+			return &fieldType{kind: kindBytes, name: name}, nil
+		case isByte(expr.Elt):
+			return &fieldType{kind: kindArray, name: name}, nil
+		case expr.Len != nil:
+			return nil, fmt.Errorf("Unsupported array type %s", name)
+		}
+		elem, err := p.resolve(expr.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindSlice, name: name, elem: elem}, nil
+	case *ast.StarExpr:
+		elem, err := p.resolve(expr.X)
+		if err != nil {
+			return nil, err
+		}
+		if elem.kind != kindStruct && elem.kind != kindMarshaler {
+			return nil, fmt.Errorf("Unsupported pointer type %s", name)
+		}
+		return &fieldType{kind: kindPtr, name: name, elem: elem}, nil
+	case *ast.SelectorExpr:
+		// types of other packages have to encode themselves
+		return &fieldType{kind: kindMarshaler, name: name}, nil
+	}
+	return nil, fmt.Errorf("Unsupported type %s", name)
+}
+
+// field is a struct field that takes part in marshalling.
+type field struct {
+	key       string
+	goName    string
+	omitEmpty bool
+	typ       *fieldType
+}
+
+// structFields returns the marshalled fields of the struct type, sorted by their key.
+func (p *pkgInfo) structFields(typeName string) ([]field, error) {
+	spec, ok := p.specs[typeName]
+	if !ok {
+		return nil, fmt.Errorf("Type %s is not found", typeName)
+	}
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("Type %s is not a struct", typeName)
+	}
+
+	var fields []field
+	seen := make(map[string]bool)
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("%s: Embedded fields are not supported", typeName)
+		}
+		var tag string
+		if f.Tag != nil {
+			raw, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(raw).Get("bencode")
+		}
+		if tag == "-" {
+			continue
+		}
+		key, opts := tag, ""
+		if idx := strings.IndexByte(tag, ','); idx >= 0 {
+			key, opts = tag[:idx], tag[idx+1:]
+		}
+
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			typ, err := p.resolve(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", typeName, ident.Name, err)
+			}
+			name := key
+			if name == "" {
+				name = ident.Name
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("%s: Duplicate key %q", typeName, name)
+			}
+			seen[name] = true
+			fields = append(fields, field{key: name, goName: ident.Name, omitEmpty: opts == "omitempty", typ: typ})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+	return fields, nil
+}
@@ -7,7 +7,7 @@ import (
 	"strconv"
 )
 
-func parseInt(reader io.ByteReader, firstChar byte) (BnCode, error) {
+func (d *Decoder) parseInt(firstChar byte) (BnCode, error) {
 	rc := BnCode{State: BnInt}
 	var buffer []byte
 
@@ -16,31 +16,32 @@ func parseInt(reader io.ByteReader, firstChar byte) (BnCode, error) {
 		return rc, fmt.Errorf("Unexpected character encountered. Expected %c but got %c", 'i', firstChar)
 	}
 
-	isNegative, hasZero := int(1), false
+	isNegative := int(1)
 
 	var err error
 	var b byte
 
 readLoop:
 	for {
-		if b, err = reader.ReadByte(); err != nil {
+		if b, err = d.reader.ReadByte(); err != nil {
 			return rc, err
 		}
 
 		switch b {
 		case '-':
+			if isNegative == -1 || len(buffer) > 0 {
+				return rc, fmt.Errorf("Unexpected character encountered. Sign is only allowed in front of the digits")
+			}
 			isNegative = -1
 		case 'e':
 			// terminate the outter loop, we found the termination delimiter
 			break readLoop
-		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
-			buffer = append(buffer, b)
-		case '0':
-			if hasZero {
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			// zero is only allowed on its own, anything following it would be a leading zero
+			if len(buffer) == 1 && buffer[0] == '0' {
 				return rc, fmt.Errorf("Leading zeros are not allowed")
 			}
 			buffer = append(buffer, b)
-			hasZero = true
 		default:
 			return rc, fmt.Errorf("Unexpected character encountered. Expected a digit, sign or e, got %c", b)
 		}
@@ -61,10 +62,10 @@ readLoop:
 	return rc, nil
 }
 
-func parseString(reader io.ByteReader, firstChar byte) (BnCode, error) {
-	rc := BnCode{State: BnString}
+// readLength reads the length prefix of a string up to and including the colon.
+func (d *Decoder) readLength(firstChar byte) (int, error) {
 	if firstChar < '0' || firstChar > '9' {
-		return rc, fmt.Errorf("Unexpected character in length")
+		return 0, fmt.Errorf("Unexpected character in length")
 	}
 
 	var buffer []byte = []byte{firstChar}
@@ -74,8 +75,8 @@ func parseString(reader io.ByteReader, firstChar byte) (BnCode, error) {
 	// attemp to read the length of a string
 readLoop:
 	for {
-		if b, err = reader.ReadByte(); err != nil {
-			return rc, err
+		if b, err = d.reader.ReadByte(); err != nil {
+			return 0, err
 		}
 		switch b {
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
@@ -84,31 +85,86 @@ readLoop:
 			break readLoop
 		default:
 			// unexpected character seen, stop
-			return rc, fmt.Errorf("Unexpected character '%c' encountered while parse string", b)
+			return 0, fmt.Errorf("Unexpected character '%c' encountered while parse string", b)
 		}
 	}
 	// get the length of the string
-	length, err := strconv.Atoi(string(buffer))
+	return strconv.Atoi(string(buffer))
+}
+
+func (d *Decoder) parseString(firstChar byte) (BnCode, error) {
+	rc := BnCode{State: BnString}
+	val, err := d.parseBytes(firstChar)
 	if err != nil {
 		return rc, err
 	}
-	// clear the buffer
-	buffer = nil
+	rc.Value = string(val)
+	return rc, nil
+}
 
-	// iterate over the entire string. throw if the length is less than the state length
-	for i := 0; i < length; i++ {
-		if b, err = reader.ReadByte(); err != nil {
-			return rc, err
+// parseBytes reads a whole string and returns its content.
+func (d *Decoder) parseBytes(firstChar byte) ([]byte, error) {
+	length, err := d.readLength(firstChar)
+	if err != nil {
+		return nil, err
+	}
+	if d.maxStringLength > 0 && length > d.maxStringLength {
+		return nil, fmt.Errorf("String of %d bytes exceeds the limit of %d bytes", length, d.maxStringLength)
+	}
+	return d.readBytes(length)
+}
+
+// stringChunk is the initial buffer size of strings, which is then doubled up to the declared length.
+// The buffer grows only as the data arrives, so a lying length prefix can not force a huge allocation.
+const stringChunk = 64 << 10
+
+// readString reads the content of a string of the given length.
+func (d *Decoder) readString(length int) (string, error) {
+	buffer, err := d.readBytes(length)
+	return string(buffer), err
+}
+
+// readBytes reads the content of a string of the given length. Readers that also implement io.Reader
+// are read in bulk, the others byte by byte.
+func (d *Decoder) readBytes(length int) ([]byte, error) {
+	size := length
+	if size > stringChunk {
+		size = stringChunk
+	}
+	buffer := make([]byte, 0, size)
+
+	r, ok := d.reader.(io.Reader)
+	if !ok {
+		for i := 0; i < length; i++ {
+			b, err := d.reader.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			buffer = append(buffer, b)
 		}
-		buffer = append(buffer, b)
+		return buffer, nil
 	}
 
-	// done parsing, record the string value
-	rc.Value = string(buffer)
-	return rc, nil
+	for len(buffer) < length {
+		if len(buffer) == cap(buffer) {
+			size = 2 * cap(buffer)
+			if size > length {
+				size = length
+			}
+			grown := make([]byte, len(buffer), size)
+			copy(grown, buffer)
+			buffer = grown
+		}
+		n, err := io.ReadFull(r, buffer[len(buffer):cap(buffer)])
+		buffer = buffer[:len(buffer)+n]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buffer, nil
 }
 
-func parseList(reader io.ByteReader, firstChar byte) (BnCode, error) {
+func (d *Decoder) parseList(firstChar byte) (BnCode, error) {
 
 	rc := BnCode{State: BnList}
 	// check if the stream starts with the correct delimiter for list
@@ -119,28 +175,38 @@ func parseList(reader io.ByteReader, firstChar byte) (BnCode, error) {
 	var tmpList []BnCode = make([]BnCode, 0)
 	var err error
 	var b byte
+	base := d.path
 
 readLoop:
 	for {
-		if b, err = reader.ReadByte(); err != nil {
+		if b, err = d.reader.ReadByte(); err != nil {
 			return rc, err
 		} // we want to return the read byte, to allow parsing methods to perform a full string scan
 		switch b {
 		case 'e':
 			break readLoop
 		default:
-			if t, err := decode(reader, b); err == nil {
-				tmpList = append(tmpList, t)
+			if d.streamFn != nil {
+				d.path = base.Index(len(tmpList))
 			}
+			t, err := d.decode(b)
+			if err != nil {
+				d.path = base
+				return rc, err
+			}
+			tmpList = append(tmpList, t)
 		}
 	}
+	d.path = base
 	rc.Value = tmpList
 	return rc, nil
 }
 
-func parseDict(reader io.ByteReader, firstChar byte) (BnCode, error) {
+func (d *Decoder) parseDict(firstChar byte) (BnCode, error) {
 	var keys []string
+	var entries OrderedDict
 	cache := make(map[string]BnCode)
+	base := d.path
 
 	rc := BnCode{State: BnDict}
 	// check if the stream starts with the correct delimiter for dict
@@ -153,7 +219,7 @@ func parseDict(reader io.ByteReader, firstChar byte) (BnCode, error) {
 
 readLoop:
 	for {
-		if b, err = reader.ReadByte(); err != nil {
+		if b, err = d.reader.ReadByte(); err != nil {
 			return rc, err
 		} // we want to return the read byte, to allow parsing methods to perform a full string scan
 		switch b {
@@ -161,13 +227,7 @@ readLoop:
 			break readLoop
 		default:
 			// read the key first, it is always expected to be a string
-			key, err := parseString(reader, b)
-			if err != nil {
-				return rc, err
-			}
-
-			// get the actual value that could be anything
-			val, err := Decode(reader)
+			key, err := d.parseString(b)
 			if err != nil {
 				return rc, err
 			}
@@ -178,11 +238,34 @@ readLoop:
 				return rc, fmt.Errorf("Unable to convert to string key, %v", err)
 			}
 
+			// get the actual value that could be anything
+			if d.streamFn != nil {
+				d.path = base.Key(keyStr)
+			}
+			val, err := d.Decode()
+			d.path = base
+			if err != nil {
+				return rc, err
+			}
+
+			if d.preserveOrder {
+				entries = append(entries, DictEntry{Key: keyStr, Value: val})
+				continue
+			}
 			keys = append(keys, keyStr)
 			cache[keyStr] = val
 		}
 	}
 
+	// the original order is what we are after, there is nothing to validate
+	if d.preserveOrder {
+		if entries == nil {
+			entries = OrderedDict{}
+		}
+		rc.Value = entries
+		return rc, nil
+	}
+
 	orgKeys := make([]string, len(keys))
 	copy(orgKeys, keys)
 	sort.Strings(keys)
@@ -198,54 +281,104 @@ readLoop:
 	return rc, nil
 }
 
-func decode(reader io.ByteReader, firstChar byte) (BnCode, error) {
+func (d *Decoder) decode(firstChar byte) (BnCode, error) {
 	var rc BnCode
 	b := firstChar
 
 	switch b {
 	// found an int
 	case 'i':
-		if obj, err := parseInt(reader, b); err == nil {
+		if obj, err := d.parseInt(b); err == nil {
 			// append the result of the int parsing to the original slice
 			rc = obj
 		} else {
 			return BnCode{}, err
 		}
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		if obj, err := parseString(reader, b); err == nil {
+		if d.streamFn != nil {
+			return d.parseStreamedString(b)
+		}
+		if obj, err := d.parseString(b); err == nil {
 			// append the result of the int parsing to the original slice
 			rc = obj
 		} else {
 			return BnCode{}, err
 		}
 	case 'l':
-		if obj, err := parseList(reader, b); err == nil {
+		if obj, err := d.parseList(b); err == nil {
 			// append the result of the int parsing to the original slice
 			rc = obj
 		} else {
 			return BnCode{}, err
 		}
 	case 'd':
-		if obj, err := parseDict(reader, b); err == nil {
+		if obj, err := d.parseDict(b); err == nil {
 			// append the result of the int parsing to the original slice
 			rc = obj
 		} else {
 			return BnCode{}, err
 		}
+	default:
+		return BnCode{}, fmt.Errorf("Unexpected character %c at the start of a node", b)
 	}
 
 	return rc, nil
 }
 
+// Decoder reads Bencode values from the incoming byte stream.
+// Use the option methods before the first call to Decode.
+type Decoder struct {
+	reader          io.ByteReader
+	preserveOrder   bool
+	maxStringLength int
+	// disallowUnknownFields is used by DecodeInto
+	disallowUnknownFields bool
+
+	streamThreshold int
+	streamFn        func(path Path, length int, r io.Reader) error
+	// path is the location of the node being decoded, tracked only when strings are streamed
+	path Path
+}
+
+// NewDecoder creates a decoder that reads from the given stream.
+func NewDecoder(reader io.ByteReader) *Decoder {
+	return &Decoder{reader: reader}
+}
+
+// PreserveOrder makes the decoder return dictionaries as OrderedDict values,
+// keeping the original key order and duplicate keys.
+// Such dictionaries are not required to have their keys sorted.
+func (d *Decoder) PreserveOrder() {
+	d.preserveOrder = true
+}
+
+// MaxStringLength limits the length of strings, longer ones are rejected before reading their content.
+// Non-positive limits disable the check.
+func (d *Decoder) MaxStringLength(n int) {
+	d.maxStringLength = n
+}
+
+// Reset makes the decoder read from the given stream, keeping its options.
+// It allows to reuse a single decoder for many streams.
+func (d *Decoder) Reset(reader io.ByteReader) {
+	d.reader = reader
+}
+
+// Decode attempts to parse the next node from the stream.
+// All subsequent nodes could be decoded with subsequent calls to this method.
+func (d *Decoder) Decode() (BnCode, error) {
+	if b, err := d.reader.ReadByte(); err != nil {
+		return BnCode{}, err
+	} else {
+		return d.decode(b)
+	}
+}
+
 // Decode attempts to parse the incoming byte stream according to Bencode rules.
 // Decodes the first encountered node, all subsequent nodes could be decoded with subsequent calls
 // to this method.
 //
 // See more details https://en.wikipedia.org/wiki/Bencode
 func Decode(reader io.ByteReader) (BnCode, error) {
-	if b, err := reader.ReadByte(); err != nil {
-		return BnCode{}, err
-	} else {
-		return decode(reader, b)
-	}
+	return NewDecoder(reader).Decode()
 }
@@ -0,0 +1,85 @@
+package bencode
+
+import (
+	"bytes"
+)
+
+// DecodeBytes decodes the node at the beginning of data and returns it along with the number of bytes it spans.
+// Data past the node is left untouched, so it could hold the payload of a message or further nodes.
+//
+// Unlike Decode, it indexes the slice directly and copies strings in one operation,
+// which makes it the faster choice when the whole input is already in memory.
+// Errors report the offset of the offending node.
+func DecodeBytes(data []byte) (BnCode, int, error) {
+	p := &indexer{data: data}
+	return p.decodeNode(0)
+}
+
+// decodeNode decodes the node starting at offset and returns the offset past its end.
+// It applies the same validation as node, without building the index.
+func (p *indexer) decodeNode(offset int) (BnCode, int, error) {
+	if offset >= len(p.data) {
+		return BnCode{}, 0, p.errorf(offset, "Unexpected end of data")
+	}
+
+	switch c := p.data[offset]; {
+	case c == 'i':
+		end, err := p.intEnd(offset)
+		if err != nil {
+			return BnCode{}, 0, err
+		}
+		val, _ := parseDigits(p.data[offset+1 : end-1])
+		return BnCode{State: BnInt, Value: val}, end, nil
+	case c >= '0' && c <= '9':
+		start, end, err := p.stringEnd(offset)
+		if err != nil {
+			return BnCode{}, 0, err
+		}
+		return BnCode{State: BnString, Value: string(p.data[start:end])}, end, nil
+	case c == 'l':
+		list := []BnCode{}
+		end := offset + 1
+		for end < len(p.data) && p.data[end] != 'e' {
+			var elem BnCode
+			var err error
+			if elem, end, err = p.decodeNode(end); err != nil {
+				return BnCode{}, 0, err
+			}
+			list = append(list, elem)
+		}
+		if end >= len(p.data) {
+			return BnCode{}, 0, p.errorf(offset, "Unterminated list")
+		}
+		return BnCode{State: BnList, Value: list}, end + 1, nil
+	case c == 'd':
+		dict := make(map[string]BnCode)
+		var prevKey []byte
+		end := offset + 1
+		for end < len(p.data) && p.data[end] != 'e' {
+			if c := p.data[end]; c < '0' || c > '9' {
+				return BnCode{}, 0, p.errorf(end, "Dictionary key must be a string")
+			}
+			start, keyEnd, err := p.stringEnd(end)
+			if err != nil {
+				return BnCode{}, 0, err
+			}
+			key := p.data[start:keyEnd]
+			if prevKey != nil && bytes.Compare(prevKey, key) > 0 {
+				return BnCode{}, 0, p.errorf(end, "Dictionary keys are not in lexicographical order")
+			}
+			prevKey = key
+
+			var val BnCode
+			if val, end, err = p.decodeNode(keyEnd); err != nil {
+				return BnCode{}, 0, err
+			}
+			dict[string(key)] = val
+		}
+		if end >= len(p.data) {
+			return BnCode{}, 0, p.errorf(offset, "Unterminated dictionary")
+		}
+		return BnCode{State: BnDict, Value: dict}, end + 1, nil
+	default:
+		return BnCode{}, 0, p.errorf(offset, "Unexpected character %c", c)
+	}
+}
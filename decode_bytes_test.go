@@ -0,0 +1,89 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    BnCode
+		wantN   int
+		wantErr bool
+	}{
+		{
+			name: "Nested dictionary",
+			data: "d4:infod6:lengthi-42e4:name1:aee",
+			want: BnCode{State: BnDict, Value: map[string]BnCode{
+				"info": {State: BnDict, Value: map[string]BnCode{
+					"length": {State: BnInt, Value: -42},
+					"name":   {State: BnString, Value: "a"},
+				}},
+			}},
+			wantN:   32,
+			wantErr: false,
+		},
+		{
+			name:    "Trailing payload",
+			data:    "li1e0:eXYZ",
+			want:    BnCode{State: BnList, Value: []BnCode{{State: BnInt, Value: 1}, {State: BnString, Value: ""}}},
+			wantN:   7,
+			wantErr: false,
+		},
+		{name: "Empty dictionary", data: "de", want: BnCode{State: BnDict, Value: map[string]BnCode{}}, wantN: 2, wantErr: false},
+		{name: "Empty data", data: "", wantErr: true},
+		{name: "Unsorted keys", data: "d1:bi1e1:ai2ee", wantErr: true},
+		{name: "Int key", data: "di1ei2ee", wantErr: true},
+		{name: "Leading zero", data: "i01e", wantErr: true},
+		{name: "Short string", data: "5:abc", wantErr: true},
+		{name: "Unterminated list", data: "li1e", wantErr: true},
+		{name: "Unterminated dictionary", data: "d1:ai1e", wantErr: true},
+		{name: "Unknown character", data: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n, err := DecodeBytes([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeBytes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) || n != tt.wantN {
+				t.Errorf("DecodeBytes() = %v, %d, want %v, %d", got, n, tt.want, tt.wantN)
+			}
+		})
+	}
+}
+
+func benchmarkTorrent() []byte {
+	pieces := strings.Repeat("x", 20*4096)
+	return []byte("d8:announce35:http://tracker.example.com/announce4:infod6:lengthi1073741824e4:name7:example12:piece lengthi262144e6:pieces81920:" + pieces + "ee")
+}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	data := benchmarkTorrent()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, _, err := DecodeBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := benchmarkTorrent()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -54,6 +55,18 @@ func Test_parseInt(t *testing.T) {
 			want:    BnCode{},
 			wantErr: true,
 		},
+		{
+			name:    "Zeros after the leading digit",
+			args:    args{reader: bytes.NewReader([]byte("100e")), firstChar: 'i'},
+			want:    BnCode{State: BnInt, Value: 100},
+			wantErr: false,
+		},
+		{
+			name:    "Leading zero",
+			args:    args{reader: bytes.NewReader([]byte("01e")), firstChar: 'i'},
+			want:    BnCode{State: BnInt},
+			wantErr: true,
+		},
 		{
 			name:    "Negative zero",
 			args:    args{reader: bytes.NewReader([]byte("-0e")), firstChar: 'i'},
@@ -63,7 +76,7 @@ func Test_parseInt(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseInt(tt.args.reader, tt.args.firstChar)
+			got, err := NewDecoder(tt.args.reader).parseInt(tt.args.firstChar)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseInt() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -128,10 +141,28 @@ func Test_parseString(t *testing.T) {
 			want:    BnCode{State: BnString},
 			wantErr: true,
 		},
+		{
+			name:    "String longer than a read chunk",
+			args:    args{reader: bytes.NewReader([]byte("00000:" + strings.Repeat("x", 200000))), firstChar: '2'},
+			want:    BnCode{State: BnString, Value: strings.Repeat("x", 200000)},
+			wantErr: false,
+		},
+		{
+			name:    "Byte by byte reader",
+			args:    args{reader: byteReader{bytes.NewReader([]byte(":foo"))}, firstChar: '3'},
+			want:    BnCode{State: BnString, Value: "foo"},
+			wantErr: false,
+		},
+		{
+			name:    "Length prefix larger than the data",
+			args:    args{reader: bytes.NewReader([]byte("000000000:foo")), firstChar: '1'},
+			want:    BnCode{State: BnString},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseString(tt.args.reader, tt.args.firstChar)
+			got, err := NewDecoder(tt.args.reader).parseString(tt.args.firstChar)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseString() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -143,6 +174,28 @@ func Test_parseString(t *testing.T) {
 	}
 }
 
+// byteReader hides all the methods of the reader besides ReadByte
+type byteReader struct {
+	r io.ByteReader
+}
+
+func (b byteReader) ReadByte() (byte, error) {
+	return b.r.ReadByte()
+}
+
+func TestDecoder_MaxStringLength(t *testing.T) {
+	d := NewDecoder(strings.NewReader("d1:a4:spame"))
+	d.MaxStringLength(3)
+	if _, err := d.Decode(); err == nil {
+		t.Errorf("Decoder.Decode() accepted a string over the limit")
+	}
+
+	d.Reset(strings.NewReader("l3:fooe"))
+	if got, err := d.Decode(); err != nil || !reflect.DeepEqual(got, BnCode{State: BnList, Value: []BnCode{{State: BnString, Value: "foo"}}}) {
+		t.Errorf("Decoder.Decode() = %v, %v", got, err)
+	}
+}
+
 func Test_parseDict(t *testing.T) {
 	type args struct {
 		reader    io.ByteReader
@@ -197,7 +250,7 @@ func Test_parseDict(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseDict(tt.args.reader, tt.args.firstChar)
+			got, err := NewDecoder(tt.args.reader).parseDict(tt.args.firstChar)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseDict() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -244,10 +297,16 @@ func Test_parseList(t *testing.T) {
 			want:    BnCode{State: BnList},
 			wantErr: true,
 		},
+		{
+			name:    "Invalid element",
+			args:    args{reader: bytes.NewReader([]byte("i42ei01ee")), firstChar: 'l'},
+			want:    BnCode{State: BnList},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseList(tt.args.reader, tt.args.firstChar)
+			got, err := NewDecoder(tt.args.reader).parseList(tt.args.firstChar)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseList() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -259,6 +318,82 @@ func Test_parseList(t *testing.T) {
 	}
 }
 
+func TestDecoder_PreserveOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    BnCode
+		wantErr bool
+	}{
+		{
+			name: "Unsorted keys with a duplicate",
+			data: "d4:spami1e3:food1:zi2e1:ai3ee4:spami4ee",
+			want: BnCode{State: BnDict, Value: OrderedDict{
+				{Key: "spam", Value: BnCode{State: BnInt, Value: 1}},
+				{Key: "foo", Value: BnCode{State: BnDict, Value: OrderedDict{
+					{Key: "z", Value: BnCode{State: BnInt, Value: 2}},
+					{Key: "a", Value: BnCode{State: BnInt, Value: 3}},
+				}}},
+				{Key: "spam", Value: BnCode{State: BnInt, Value: 4}},
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "Empty dictionary",
+			data:    "de",
+			want:    BnCode{State: BnDict, Value: OrderedDict{}},
+			wantErr: false,
+		},
+		{
+			name:    "Key without a value",
+			data:    "d3:foo",
+			want:    BnCode{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader([]byte(tt.data)))
+			d.PreserveOrder()
+			got, err := d.Decode()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decoder.Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decoder.Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderedDict_Get(t *testing.T) {
+	node := BnCode{State: BnDict, Value: OrderedDict{
+		{Key: "b", Value: BnCode{State: BnInt, Value: 1}},
+		{Key: "a", Value: BnCode{State: BnInt, Value: 2}},
+		{Key: "b", Value: BnCode{State: BnInt, Value: 3}},
+	}}
+	ordered, err := node.GetOrderedDict()
+	if err != nil {
+		t.Fatalf("GetOrderedDict() error = %v", err)
+	}
+	if got, ok := ordered.Get("b"); !ok || got.Value != 3 {
+		t.Errorf("OrderedDict.Get() = %v, %v, want the last duplicate", got, ok)
+	}
+	if _, ok := ordered.Get("c"); ok {
+		t.Errorf("OrderedDict.Get() found a missing key")
+	}
+
+	dict, err := node.GetDict()
+	if err != nil {
+		t.Fatalf("GetDict() error = %v", err)
+	}
+	want := map[string]BnCode{"a": {State: BnInt, Value: 2}, "b": {State: BnInt, Value: 3}}
+	if !reflect.DeepEqual(dict, want) {
+		t.Errorf("GetDict() = %v, want %v", dict, want)
+	}
+}
+
 func Test_decode(t *testing.T) {
 	type args struct {
 		reader    io.ByteReader
@@ -270,11 +405,22 @@ func Test_decode(t *testing.T) {
 		want    BnCode
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name:    "Int",
+			args:    args{reader: bytes.NewReader([]byte("7e")), firstChar: 'i'},
+			want:    BnCode{State: BnInt, Value: 7},
+			wantErr: false,
+		},
+		{
+			name:    "Unknown first character",
+			args:    args{reader: bytes.NewReader([]byte("7e")), firstChar: 'x'},
+			want:    BnCode{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := decode(tt.args.reader, tt.args.firstChar)
+			got, err := NewDecoder(tt.args.reader).decode(tt.args.firstChar)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("decode() error = %v, wantErr %v", err, tt.wantErr)
 				return
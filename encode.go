@@ -2,128 +2,144 @@ package bencode
 
 import (
 	"fmt"
-	"sort"
 	"strconv"
+	"sync"
 )
 
-func flattenInt(src BnCode) ([]byte, error) {
-	rc := []byte{}
+func flattenInt(dst []byte, src BnCode) ([]byte, error) {
 	if src.State != BnInt {
-		return rc, fmt.Errorf("Source object does not hold an int value")
+		return dst, fmt.Errorf("Source object does not hold an int value")
 	}
 
 	val, err := src.GetInt()
 	if err != nil {
-		return rc, err
+		return dst, err
 	}
 
-	rc = append(rc, 'i')
-	for _, v := range []byte(strconv.Itoa(val)) {
-		rc = append(rc, v)
-	}
-	rc = append(rc, 'e')
-
-	return rc, nil
+	dst = append(dst, 'i')
+	dst = strconv.AppendInt(dst, int64(val), 10)
+	return append(dst, 'e'), nil
 }
 
-func flattenString(src BnCode) ([]byte, error) {
-	rc := []byte{}
+func flattenString(dst []byte, src BnCode) ([]byte, error) {
 	if src.State != BnString {
-		return rc, fmt.Errorf("Source object does not hold a string value")
+		return dst, fmt.Errorf("Source object does not hold a string value")
 	}
 
 	val, err := src.GetString()
 	if err != nil {
-		return rc, err
+		return dst, err
 	}
-	rc = []byte(strconv.Itoa(len(val)))
-
-	rc = append(rc, ':')
-	rc = append(rc, []byte(val)...)
-
-	return rc, nil
+	return appendString(dst, val), nil
 }
 
-func flattenList(src BnCode) ([]byte, error) {
-	rc, tmp := []byte{}, []byte{}
+func flattenList(dst []byte, src BnCode) ([]byte, error) {
 	if src.State != BnList {
-		return tmp, fmt.Errorf("Source object does not hold a list value")
+		return dst, fmt.Errorf("Source object does not hold a list value")
 	}
 
 	val, err := src.GetList()
 	if err != nil {
-		return tmp, err
+		return dst, err
 	}
 
-	rc = append(rc, 'l')
-	var enc []byte
+	// children are appended directly, on failure the partial output is dropped
+	rc := append(dst, 'l')
 	for _, v := range val {
-		if enc, err = Encode(v); err != nil {
-			return tmp, err
+		if rc, err = AppendEncode(rc, v); err != nil {
+			return dst, err
 		}
-		rc = append(rc, enc...)
 	}
-
-	rc = append(rc, 'e')
-
-	return rc, nil
+	return append(rc, 'e'), nil
 }
 
-func flattenDict(src BnCode) ([]byte, error) {
-	rc, emptyRc := []byte{}, []byte{}
+func flattenDict(dst []byte, src BnCode) ([]byte, error) {
 	if src.State != BnDict {
-		return emptyRc, fmt.Errorf("Source object does not hold a dictionary")
+		return dst, fmt.Errorf("Source object does not hold a dictionary")
+	}
+
+	if ordered, ok := src.Value.(OrderedDict); ok {
+		return flattenOrderedDict(dst, ordered)
 	}
 
 	val, err := src.GetDict()
 	if err != nil {
-		return emptyRc, err
+		return dst, err
 	}
 
 	// we need to insert the keys in the sorted order, hence
 	// we collect and sort the keys first and then iterate over
 	// the sorted keys encoding them in the correct order
-	keys := make([]string, len(val))
-	i := 0
-	for key := range val {
-		keys[i] = key
-		i++
-	}
-	sort.Strings(keys)
-
-	rc = append(rc, 'd')
-	for _, key := range keys {
-		v := val[key]
-		// encode the key by creating a tmp wrapper object
-		tmp := BnCode{State: BnString, Value: key}
-		enc, err := flattenString(tmp)
-		rc = append(rc, enc...)
-
-		// insert the actual value
-		if enc, err = Encode(v); err != nil {
-			return emptyRc, err
+	rc := append(dst, 'd')
+	for _, key := range sortedKeys(val) {
+		rc = appendString(rc, key)
+		if rc, err = AppendEncode(rc, val[key]); err != nil {
+			return dst, err
 		}
-		rc = append(rc, enc...)
 	}
-	rc = append(rc, 'e')
+	return append(rc, 'e'), nil
+}
 
-	return rc, nil
+func flattenOrderedDict(dst []byte, val OrderedDict) ([]byte, error) {
+	var err error
+	rc := append(dst, 'd')
+	// entries are written in their original order, even if it is not the canonical one
+	for _, e := range val {
+		rc = appendString(rc, e.Key)
+		if rc, err = AppendEncode(rc, e.Value); err != nil {
+			return dst, err
+		}
+	}
+	return append(rc, 'e'), nil
 }
 
-// Encode attempts to flatten the src BnCode object into dest stream.
+// AppendEncode appends the encoding of src to dst and returns the extended buffer.
+// It allocates only if dst has to grow, so reusing the returned buffer makes encoding allocation free.
 //
-// Follows rules described here: https://en.wikipedia.org/wiki/Bencode
-func Encode(src BnCode) ([]byte, error) {
+// Returns dst unchanged along with the error if src can not be encoded.
+func AppendEncode(dst []byte, src BnCode) ([]byte, error) {
 	switch src.State {
 	case BnInt:
-		return flattenInt(src)
+		return flattenInt(dst, src)
 	case BnString:
-		return flattenString(src)
+		return flattenString(dst, src)
 	case BnList:
-		return flattenList(src)
+		return flattenList(dst, src)
 	case BnDict:
-		return flattenDict(src)
+		return flattenDict(dst, src)
 	default:
-		return []byte{}, fmt.Errorf("Unknown type encountered")
+		return dst, fmt.Errorf("Unknown type encountered")
+	}
+}
+
+// maxPooledBuffer is the capacity above which encode buffers are left to the garbage collector,
+// so a single huge document does not stay pinned in the pool.
+const maxPooledBuffer = 64 << 10
+
+var encodePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// Encode attempts to flatten the src BnCode object into dest stream.
+// The encoding is built in a pooled buffer, only the returned copy is allocated.
+//
+// Follows rules described here: https://en.wikipedia.org/wiki/Bencode
+func Encode(src BnCode) ([]byte, error) {
+	buf := encodePool.Get().(*[]byte)
+	enc, err := AppendEncode((*buf)[:0], src)
+	if err != nil {
+		encodePool.Put(buf)
+		return []byte{}, err
+	}
+
+	rc := make([]byte, len(enc))
+	copy(rc, enc)
+	if cap(enc) <= maxPooledBuffer {
+		*buf = enc
+		encodePool.Put(buf)
 	}
+	return rc, nil
 }
@@ -42,7 +42,7 @@ func Test_flattenInt(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := flattenInt(tt.args.src)
+			got, err := flattenInt([]byte{}, tt.args.src)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("flattenInt() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -99,7 +99,7 @@ func Test_flattenString(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := flattenString(tt.args.src)
+			got, err := flattenString([]byte{}, tt.args.src)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("flattenString() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -156,7 +156,7 @@ func Test_flattenList(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := flattenList(tt.args.src)
+			got, err := flattenList([]byte{}, tt.args.src)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("flattenList() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -210,11 +210,31 @@ func Test_flattenDict(t *testing.T) {
 			want:    []byte("de"),
 			wantErr: false,
 		},
+		{
+			name: "Ordered dictionary keeps the order and duplicates",
+			args: args{
+				src: BnCode{State: BnDict, Value: OrderedDict{
+					{Key: "z", Value: BnCode{State: BnInt, Value: 42}},
+					{Key: "a", Value: BnCode{State: BnString, Value: "foobar"}},
+					{Key: "z", Value: BnCode{State: BnInt, Value: 7}},
+				}},
+			},
+			want:    []byte("d1:zi42e1:a6:foobar1:zi7ee"),
+			wantErr: false,
+		},
+		{
+			name: "Ordered dictionary with invalid value",
+			args: args{
+				src: BnCode{State: BnDict, Value: OrderedDict{{Key: "a", Value: BnCode{State: 7}}}},
+			},
+			want:    []byte(""),
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := flattenDict(tt.args.src)
+			got, err := flattenDict([]byte{}, tt.args.src)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("flattenDict() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -290,3 +310,82 @@ func TestEncode(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendEncode(t *testing.T) {
+	dst := []byte("prefix")
+	got, err := AppendEncode(dst, BnCode{State: BnList, Value: []BnCode{{State: BnInt, Value: 1}, {State: BnString, Value: "a"}}})
+	if err != nil || string(got) != "prefixli1e1:ae" {
+		t.Errorf("AppendEncode() = %s, %v, want prefixli1e1:ae", got, err)
+	}
+
+	broken := BnCode{State: BnDict, Value: map[string]BnCode{"a": {State: BnInt, Value: 1}, "b": {State: BnList, Value: nil}}}
+	got, err = AppendEncode(dst, broken)
+	if err == nil || string(got) != "prefix" {
+		t.Errorf("AppendEncode() = %s, %v, want the unchanged buffer and an error", got, err)
+	}
+}
+
+func TestAppendEncode_Allocs(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	for _, src := range []BnCode{{State: BnInt, Value: -1234567}, {State: BnString, Value: "announce"}} {
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, _ = AppendEncode(buf[:0], src)
+		})
+		if allocs != 0 {
+			t.Errorf("AppendEncode(%v) made %v allocations, want 0", src, allocs)
+		}
+	}
+}
+
+func benchmarkDict() BnCode {
+	files := make([]BnCode, 0, 16)
+	for i := 0; i < 16; i++ {
+		files = append(files, BnCode{State: BnDict, Value: map[string]BnCode{
+			"length": {State: BnInt, Value: i * 1024},
+			"path":   {State: BnList, Value: []BnCode{{State: BnString, Value: "dir"}, {State: BnString, Value: "file.bin"}}},
+		}})
+	}
+	return BnCode{State: BnDict, Value: map[string]BnCode{
+		"announce": {State: BnString, Value: "http://tracker.example.com/announce"},
+		"info": {State: BnDict, Value: map[string]BnCode{
+			"files":        {State: BnList, Value: files},
+			"name":         {State: BnString, Value: "example"},
+			"piece length": {State: BnInt, Value: 262144},
+		}},
+	}}
+}
+
+func BenchmarkAppendEncode_Int(b *testing.B) {
+	src := BnCode{State: BnInt, Value: -1234567}
+	buf := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendEncode(buf[:0], src)
+	}
+}
+
+func BenchmarkAppendEncode_String(b *testing.B) {
+	src := BnCode{State: BnString, Value: "http://tracker.example.com/announce"}
+	buf := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendEncode(buf[:0], src)
+	}
+}
+
+func BenchmarkAppendEncode_Dict(b *testing.B) {
+	src := benchmarkDict()
+	buf := make([]byte, 0, 4096)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendEncode(buf[:0], src)
+	}
+}
+
+func BenchmarkEncode_Dict(b *testing.B) {
+	src := benchmarkDict()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = Encode(src)
+	}
+}
@@ -0,0 +1,114 @@
+package bencode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// encoderFlushSize is the amount of buffered output after which Encoder writes it out.
+const encoderFlushSize = 32 << 10
+
+// Encoder writes Bencode values to an output stream.
+//
+// Unlike Encode, it accepts BnString nodes with StreamedString values and copies their content
+// from the reader straight to the output, so huge strings never have to be held in memory.
+type Encoder struct {
+	writer io.Writer
+	buf    []byte
+}
+
+// NewEncoder creates an encoder that writes to the given stream.
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{writer: writer}
+}
+
+// Encode writes the encoding of the node to the stream.
+// On error the stream could hold a partial encoding.
+func (e *Encoder) Encode(node BnCode) error {
+	if err := e.encode(node); err != nil {
+		e.buf = e.buf[:0]
+		return err
+	}
+	return e.flush()
+}
+
+func (e *Encoder) flush() error {
+	if len(e.buf) == 0 {
+		return nil
+	}
+	_, err := e.writer.Write(e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
+
+func (e *Encoder) encode(node BnCode) error {
+	if len(e.buf) >= encoderFlushSize {
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+
+	switch node.State {
+	case BnString:
+		if s, ok := node.Value.(StreamedString); ok {
+			return e.encodeStreamed(s)
+		}
+	case BnList:
+		list, err := node.GetList()
+		if err != nil {
+			return err
+		}
+		e.buf = append(e.buf, 'l')
+		for _, v := range list {
+			if err = e.encode(v); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, 'e')
+		return nil
+	case BnDict:
+		e.buf = append(e.buf, 'd')
+		if ordered, ok := node.Value.(OrderedDict); ok {
+			for _, entry := range ordered {
+				e.buf = appendString(e.buf, entry.Key)
+				if err := e.encode(entry.Value); err != nil {
+					return err
+				}
+			}
+		} else {
+			dict, err := node.GetDict()
+			if err != nil {
+				return err
+			}
+			for _, k := range sortedKeys(dict) {
+				e.buf = appendString(e.buf, k)
+				if err = e.encode(dict[k]); err != nil {
+					return err
+				}
+			}
+		}
+		e.buf = append(e.buf, 'e')
+		return nil
+	}
+
+	var err error
+	e.buf, err = AppendEncode(e.buf, node)
+	return err
+}
+
+func (e *Encoder) encodeStreamed(s StreamedString) error {
+	if s.Reader == nil || s.Length < 0 {
+		return fmt.Errorf("Streamed string has no content to encode")
+	}
+	e.buf = strconv.AppendInt(e.buf, int64(s.Length), 10)
+	e.buf = append(e.buf, ':')
+	if err := e.flush(); err != nil {
+		return err
+	}
+	n, err := io.CopyN(e.writer, s.Reader, int64(s.Length))
+	if err == io.EOF {
+		return fmt.Errorf("Streamed string reader returned %d of %d bytes", n, s.Length)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package bencode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncoder_Encode(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    BnCode
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Streamed strings",
+			node: BnCode{State: BnDict, Value: map[string]BnCode{
+				"b": {State: BnList, Value: []BnCode{{State: BnString, Value: StreamedString{Length: 3, Reader: strings.NewReader("xyzextra")}}}},
+				"a": {State: BnInt, Value: 1},
+			}},
+			want:    "d1:ai1e1:bl3:xyzee",
+			wantErr: false,
+		},
+		{
+			name: "Ordered dictionary",
+			node: BnCode{State: BnDict, Value: OrderedDict{
+				{Key: "z", Value: BnCode{State: BnString, Value: "s"}},
+				{Key: "a", Value: BnCode{State: BnString, Value: StreamedString{Length: 0, Reader: strings.NewReader("")}}},
+			}},
+			want:    "d1:z1:s1:a0:e",
+			wantErr: false,
+		},
+		{
+			name:    "Short reader",
+			node:    BnCode{State: BnString, Value: StreamedString{Length: 5, Reader: strings.NewReader("abc")}},
+			wantErr: true,
+		},
+		{
+			name:    "Decoded streamed string",
+			node:    BnCode{State: BnString, Value: StreamedString{Length: 5}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid list",
+			node:    BnCode{State: BnList, Value: "x"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewEncoder(&buf).Encode(tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Encoder.Encode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("Encoder.Encode() = %s, want %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoder_EncodeLarge(t *testing.T) {
+	list := make([]BnCode, 100)
+	for i := range list {
+		list[i] = benchmarkDict()
+	}
+	node := BnCode{State: BnList, Value: list}
+	want, err := Encode(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for i := 0; i < 3; i++ {
+		if err = e.Encode(node); err != nil {
+			t.Fatalf("Encoder.Encode() error = %v", err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), bytes.Repeat(want, 3)) {
+		t.Errorf("Encoder.Encode() output differs from Encode")
+	}
+}
@@ -0,0 +1,172 @@
+// Package extension implements the payloads of the peer wire extension protocol (BEP 10)
+// and of the extensions built on top of it.
+//
+// See more details http://bittorrent.org/beps/bep_0010.html
+package extension
+
+import (
+	"fmt"
+	"net"
+
+	"bencode"
+)
+
+const (
+	// MessageID is the peer wire message id reserved for extension messages
+	MessageID = 20
+	// HandshakeID is the extended message id of the handshake
+	HandshakeID = 0
+)
+
+// Handshake is the payload of the extended message with id HandshakeID.
+// Zero valued fields are omitted from the encoded message, except M which is always present.
+type Handshake struct {
+	// M maps the names of supported extensions to their local message ids, 0 disables the extension
+	M map[string]int
+	// V is the client name and version
+	V string
+	// P is the local TCP listen port
+	P int
+	// ReqQ is the number of outstanding requests the client supports without dropping any
+	ReqQ int
+	// YourIP is the address of the remote peer as seen by the client
+	YourIP net.IP
+	// MetadataSize is the size of the info dictionary, required to support ut_metadata (BEP 9)
+	MetadataSize int
+}
+
+func str(s string) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnString, Value: s}
+}
+
+func integer(i int) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnInt, Value: i}
+}
+
+// Marshal encodes the handshake payload.
+func (h *Handshake) Marshal() ([]byte, error) {
+	m := make(map[string]bencode.BnCode, len(h.M))
+	for name, id := range h.M {
+		if id < 0 || id > 0xff {
+			return nil, fmt.Errorf("Extension %q has message id %d out of range", name, id)
+		}
+		m[name] = integer(id)
+	}
+
+	dict := map[string]bencode.BnCode{"m": {State: bencode.BnDict, Value: m}}
+	if h.V != "" {
+		dict["v"] = str(h.V)
+	}
+	if h.P != 0 {
+		if h.P < 0 || h.P > 0xffff {
+			return nil, fmt.Errorf("Port %d is out of range", h.P)
+		}
+		dict["p"] = integer(h.P)
+	}
+	if h.ReqQ != 0 {
+		dict["reqq"] = integer(h.ReqQ)
+	}
+	if h.YourIP != nil {
+		ip := h.YourIP.To4()
+		if ip == nil {
+			ip = h.YourIP.To16()
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid yourip address %v", h.YourIP)
+		}
+		dict["yourip"] = str(string(ip))
+	}
+	if h.MetadataSize != 0 {
+		dict["metadata_size"] = integer(h.MetadataSize)
+	}
+
+	return bencode.Encode(bencode.BnCode{State: bencode.BnDict, Value: dict})
+}
+
+// UnmarshalHandshake parses the handshake payload. Unknown keys are ignored.
+func UnmarshalHandshake(data []byte) (*Handshake, error) {
+	node, n, err := bencode.DecodeBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, fmt.Errorf("Unexpected %d trailing bytes after the handshake", len(data)-n)
+	}
+	dict, err := node.GetDict()
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &Handshake{}
+	if val, ok := dict["m"]; ok {
+		m, err := val.GetDict()
+		if err != nil {
+			return nil, fmt.Errorf("Key %q: %v", "m", err)
+		}
+		rc.M = make(map[string]int, len(m))
+		for name, v := range m {
+			if rc.M[name], err = v.GetInt(); err != nil {
+				return nil, fmt.Errorf("Extension %q: %v", name, err)
+			}
+		}
+	}
+	if rc.V, err = optionalString(dict, "v"); err != nil {
+		return nil, err
+	}
+	if rc.P, err = optionalInt(dict, "p"); err != nil {
+		return nil, err
+	}
+	if rc.ReqQ, err = optionalInt(dict, "reqq"); err != nil {
+		return nil, err
+	}
+	if rc.MetadataSize, err = optionalInt(dict, "metadata_size"); err != nil {
+		return nil, err
+	}
+	if rc.MetadataSize < 0 {
+		return nil, fmt.Errorf("Negative metadata_size %d", rc.MetadataSize)
+	}
+
+	ip, err := optionalString(dict, "yourip")
+	if err != nil {
+		return nil, err
+	}
+	switch len(ip) {
+	case 0:
+	case net.IPv4len, net.IPv6len:
+		rc.YourIP = net.IP(ip)
+	default:
+		return nil, fmt.Errorf("Key %q must be %d or %d bytes long, got %d", "yourip", net.IPv4len, net.IPv6len, len(ip))
+	}
+	return rc, nil
+}
+
+func optionalString(dict map[string]bencode.BnCode, key string) (string, error) {
+	val, ok := dict[key]
+	if !ok {
+		return "", nil
+	}
+	rc, err := val.GetString()
+	if err != nil {
+		return "", fmt.Errorf("Key %q: %v", key, err)
+	}
+	return rc, nil
+}
+
+func optionalInt(dict map[string]bencode.BnCode, key string) (int, error) {
+	val, ok := dict[key]
+	if !ok {
+		return 0, nil
+	}
+	rc, err := val.GetInt()
+	if err != nil {
+		return 0, fmt.Errorf("Key %q: %v", key, err)
+	}
+	return rc, nil
+}
+
+func requireInt(dict map[string]bencode.BnCode, key string) (int, error) {
+	if _, ok := dict[key]; !ok {
+		return 0, fmt.Errorf("Required key %q is missing", key)
+	}
+	return optionalInt(dict, key)
+}
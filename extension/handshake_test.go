@@ -0,0 +1,110 @@
+package extension
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalHandshake(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *Handshake
+		wantErr bool
+	}{
+		{
+			name: "Positive test case",
+			data: "d1:md11:ut_metadatai1e6:ut_pexi2ee13:metadata_sizei31235e1:pi6881e4:reqqi500e1:v12:uTorrent 1.26:yourip4:\x0a\x00\x00\x01e",
+			want: &Handshake{
+				M:            map[string]int{"ut_metadata": 1, "ut_pex": 2},
+				V:            "uTorrent 1.2",
+				P:            6881,
+				ReqQ:         500,
+				YourIP:       net.IP{10, 0, 0, 1},
+				MetadataSize: 31235,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Unknown keys are ignored",
+			data:    "d1:md6:ut_pexi0ee7:unknowni1ee",
+			want:    &Handshake{M: map[string]int{"ut_pex": 0}},
+			wantErr: false,
+		},
+		{
+			name:    "Message id is not an int",
+			data:    "d1:md6:ut_pex1:xee",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Malformed yourip",
+			data:    "d1:mde6:yourip3:abce",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Trailing data",
+			data:    "d1:mdeexx",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalHandshake([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalHandshake() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalHandshake() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandshake_Marshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Handshake
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Positive test case",
+			h: Handshake{
+				M:      map[string]int{"ut_pex": 2, "ut_metadata": 1},
+				P:      6881,
+				YourIP: net.IPv4(10, 0, 0, 1),
+			},
+			want:    "d1:md11:ut_metadatai1e6:ut_pexi2ee1:pi6881e6:yourip4:\x0a\x00\x00\x01e",
+			wantErr: false,
+		},
+		{
+			name:    "Empty handshake",
+			h:       Handshake{},
+			want:    "d1:mdee",
+			wantErr: false,
+		},
+		{
+			name:    "Message id out of range",
+			h:       Handshake{M: map[string]int{"ut_pex": 256}},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.Marshal()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Handshake.Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("Handshake.Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
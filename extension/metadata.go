@@ -0,0 +1,109 @@
+package extension
+
+import (
+	"fmt"
+
+	"bencode"
+)
+
+// MetadataName is the name of the metadata exchange extension (BEP 9) in the handshake "m" dictionary
+const MetadataName = "ut_metadata"
+
+// MetadataPieceSize is the size of every metadata piece except the last one
+const MetadataPieceSize = 16 * 1024
+
+const (
+	// MetadataRequest asks for a piece of the info dictionary
+	MetadataRequest = 0
+	// MetadataData carries a piece of the info dictionary
+	MetadataData = 1
+	// MetadataReject tells that the requested piece is not available
+	MetadataReject = 2
+)
+
+// MetadataMessage is the payload of a ut_metadata message (BEP 9).
+//
+// The bencoded dictionary of a data message is followed by the raw piece,
+// which is kept in Data.
+type MetadataMessage struct {
+	Type  int
+	Piece int
+	// TotalSize is the size of the info dictionary, only sent with data messages
+	TotalSize int
+	// Data is the piece of the info dictionary, only sent with data messages
+	Data []byte
+}
+
+// Marshal encodes the message payload, appending the piece data to the dictionary.
+func (m *MetadataMessage) Marshal() ([]byte, error) {
+	if m.Piece < 0 {
+		return nil, fmt.Errorf("Negative piece index %d", m.Piece)
+	}
+	dict := map[string]bencode.BnCode{
+		"msg_type": integer(m.Type),
+		"piece":    integer(m.Piece),
+	}
+
+	switch m.Type {
+	case MetadataRequest, MetadataReject:
+		if len(m.Data) != 0 {
+			return nil, fmt.Errorf("Message type %d can not carry data", m.Type)
+		}
+	case MetadataData:
+		if len(m.Data) > MetadataPieceSize {
+			return nil, fmt.Errorf("Piece is %d bytes long, at most %d allowed", len(m.Data), MetadataPieceSize)
+		}
+		dict["total_size"] = integer(m.TotalSize)
+	default:
+		return nil, fmt.Errorf("Unknown message type %d", m.Type)
+	}
+
+	rc, err := bencode.Encode(bencode.BnCode{State: bencode.BnDict, Value: dict})
+	if err != nil {
+		return nil, err
+	}
+	return append(rc, m.Data...), nil
+}
+
+// UnmarshalMetadata parses the message payload. For data messages everything
+// that follows the bencoded dictionary is returned as the piece data, copied
+// so the payload buffer can be reused for the next message.
+func UnmarshalMetadata(data []byte) (*MetadataMessage, error) {
+	node, n, err := bencode.DecodeBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	dict, err := node.GetDict()
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &MetadataMessage{}
+	if rc.Type, err = requireInt(dict, "msg_type"); err != nil {
+		return nil, err
+	}
+	if rc.Piece, err = requireInt(dict, "piece"); err != nil {
+		return nil, err
+	}
+	if rc.Piece < 0 {
+		return nil, fmt.Errorf("Negative piece index %d", rc.Piece)
+	}
+
+	switch rc.Type {
+	case MetadataRequest, MetadataReject:
+		if n != len(data) {
+			return nil, fmt.Errorf("Message type %d can not carry data", rc.Type)
+		}
+	case MetadataData:
+		if rc.TotalSize, err = requireInt(dict, "total_size"); err != nil {
+			return nil, err
+		}
+		if len(data)-n > MetadataPieceSize {
+			return nil, fmt.Errorf("Piece is %d bytes long, at most %d allowed", len(data)-n, MetadataPieceSize)
+		}
+		rc.Data = append([]byte{}, data[n:]...)
+	default:
+		return nil, fmt.Errorf("Unknown message type %d", rc.Type)
+	}
+	return rc, nil
+}
@@ -0,0 +1,108 @@
+package extension
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *MetadataMessage
+		wantErr bool
+	}{
+		{
+			name:    "Request",
+			data:    "d8:msg_typei0e5:piecei0ee",
+			want:    &MetadataMessage{Type: MetadataRequest, Piece: 0},
+			wantErr: false,
+		},
+		{
+			name:    "Data followed by the piece",
+			data:    "d8:msg_typei1e5:piecei2e10:total_sizei34256eexxxxxxxx",
+			want:    &MetadataMessage{Type: MetadataData, Piece: 2, TotalSize: 34256, Data: []byte("xxxxxxxx")},
+			wantErr: false,
+		},
+		{
+			name:    "Reject",
+			data:    "d8:msg_typei2e5:piecei1ee",
+			want:    &MetadataMessage{Type: MetadataReject, Piece: 1},
+			wantErr: false,
+		},
+		{
+			name:    "Request with trailing data",
+			data:    "d8:msg_typei0e5:piecei0eexx",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Data without total size",
+			data:    "d8:msg_typei1e5:piecei0eexx",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown type",
+			data:    "d8:msg_typei7e5:piecei0ee",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Truncated dictionary",
+			data:    "d8:msg_typei1e5:piece",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalMetadata([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalMetadata() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalMetadata() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMetadata_copiesData(t *testing.T) {
+	data := []byte("d8:msg_typei1e5:piecei0e10:total_sizei3eeabc")
+	got, err := UnmarshalMetadata(data)
+	if err != nil {
+		t.Fatalf("UnmarshalMetadata() error = %v", err)
+	}
+	copy(data[len(data)-3:], "xyz")
+	if string(got.Data) != "abc" {
+		t.Errorf("UnmarshalMetadata() Data = %q after reusing the payload, want %q", got.Data, "abc")
+	}
+}
+
+func TestMetadataMessage_Marshal(t *testing.T) {
+	piece := bytes.Repeat([]byte{0xff}, MetadataPieceSize)
+	msg := &MetadataMessage{Type: MetadataData, Piece: 1, TotalSize: 2 * MetadataPieceSize, Data: piece}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("MetadataMessage.Marshal() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("d8:msg_typei1e5:piecei1e10:total_sizei32768ee")) {
+		t.Errorf("MetadataMessage.Marshal() has unexpected prefix %q", data[:48])
+	}
+
+	got, err := UnmarshalMetadata(data)
+	if err != nil {
+		t.Fatalf("UnmarshalMetadata() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Errorf("UnmarshalMetadata() = %v, want %v", got, msg)
+	}
+
+	msg.Data = append(msg.Data, 0)
+	if _, err = msg.Marshal(); err == nil {
+		t.Errorf("MetadataMessage.Marshal() accepted an oversized piece")
+	}
+}
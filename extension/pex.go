@@ -0,0 +1,191 @@
+package extension
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"bencode"
+)
+
+// PexName is the name of the peer exchange extension (BEP 11) in the handshake "m" dictionary
+const PexName = "ut_pex"
+
+// PexMaxPeers is the largest number of added or dropped peers allowed in a single message
+const PexMaxPeers = 50
+
+// PexFlags describe an added peer, one byte per peer in "added.f" and "added6.f".
+type PexFlags byte
+
+const (
+	// PexEncryption marks a peer that prefers encrypted connections
+	PexEncryption PexFlags = 1 << iota
+	// PexSeed marks a seed or an upload only peer
+	PexSeed
+	// PexUTP marks a peer that supports uTP
+	PexUTP
+	// PexHolepunch marks a peer that supports the ut_holepunch extension
+	PexHolepunch
+	// PexReachable marks a peer the sender connected to, so it accepts incoming connections
+	PexReachable
+)
+
+// Has tells whether all of the given flags are set.
+func (f PexFlags) Has(flags PexFlags) bool {
+	return f&flags == flags
+}
+
+// Set returns the flags with the given flags set or cleared.
+func (f PexFlags) Set(flags PexFlags, on bool) PexFlags {
+	if on {
+		return f | flags
+	}
+	return f &^ flags
+}
+
+// PexPeer is a peer address together with its flags. Flags of dropped peers are not sent.
+type PexPeer struct {
+	IP    net.IP
+	Port  int
+	Flags PexFlags
+}
+
+// PexMessage is the payload of a ut_pex message. Empty lists are omitted from the encoded message.
+type PexMessage struct {
+	Added    []PexPeer
+	Dropped  []PexPeer
+	Added6   []PexPeer
+	Dropped6 []PexPeer
+}
+
+func encodePeers(peers []PexPeer, ipLen int) (string, string, error) {
+	if len(peers) > PexMaxPeers {
+		return "", "", fmt.Errorf("%d peers in a single message, at most %d allowed", len(peers), PexMaxPeers)
+	}
+	size := ipLen + 2
+	addrs, flags := make([]byte, len(peers)*size), make([]byte, len(peers))
+	for i, p := range peers {
+		var ip net.IP
+		if ipLen == net.IPv4len {
+			ip = p.IP.To4()
+		} else if p.IP.To4() == nil {
+			ip = p.IP.To16()
+		}
+		if ip == nil {
+			return "", "", fmt.Errorf("Peer %v does not match the address family of the list", p.IP)
+		}
+		if p.Port < 0 || p.Port > 0xffff {
+			return "", "", fmt.Errorf("Port %d is out of range", p.Port)
+		}
+		copy(addrs[i*size:], ip)
+		binary.BigEndian.PutUint16(addrs[i*size+ipLen:], uint16(p.Port))
+		flags[i] = byte(p.Flags)
+	}
+	return string(addrs), string(flags), nil
+}
+
+func decodePeers(dict map[string]bencode.BnCode, key string, ipLen int) ([]PexPeer, error) {
+	addrs, err := optionalString(dict, key)
+	if err != nil || addrs == "" {
+		return nil, err
+	}
+	size := ipLen + 2
+	if len(addrs)%size != 0 {
+		return nil, fmt.Errorf("Key %q: length %d is not a multiple of %d", key, len(addrs), size)
+	}
+	if len(addrs)/size > PexMaxPeers {
+		return nil, fmt.Errorf("Key %q: %d peers in a single message, at most %d allowed", key, len(addrs)/size, PexMaxPeers)
+	}
+
+	rc := make([]PexPeer, len(addrs)/size)
+	for i := range rc {
+		entry := addrs[i*size : (i+1)*size]
+		rc[i].IP = net.IP(entry[:ipLen])
+		rc[i].Port = int(binary.BigEndian.Uint16([]byte(entry[ipLen:])))
+	}
+	return rc, nil
+}
+
+func decodeFlags(dict map[string]bencode.BnCode, key string, peers []PexPeer) error {
+	flags, err := optionalString(dict, key)
+	if err != nil {
+		return err
+	}
+	if _, ok := dict[key]; ok && len(flags) != len(peers) {
+		return fmt.Errorf("Key %q: got %d flags for %d peers", key, len(flags), len(peers))
+	}
+	for i := range flags {
+		peers[i].Flags = PexFlags(flags[i])
+	}
+	return nil
+}
+
+// Marshal encodes the message payload.
+func (m *PexMessage) Marshal() ([]byte, error) {
+	dict := make(map[string]bencode.BnCode)
+	put := func(key string, peers []PexPeer, ipLen int, withFlags bool) error {
+		if len(peers) == 0 {
+			return nil
+		}
+		addrs, flags, err := encodePeers(peers, ipLen)
+		if err != nil {
+			return fmt.Errorf("Key %q: %v", key, err)
+		}
+		dict[key] = str(addrs)
+		if withFlags {
+			dict[key+".f"] = str(flags)
+		}
+		return nil
+	}
+
+	if err := put("added", m.Added, net.IPv4len, true); err != nil {
+		return nil, err
+	}
+	if err := put("dropped", m.Dropped, net.IPv4len, false); err != nil {
+		return nil, err
+	}
+	if err := put("added6", m.Added6, net.IPv6len, true); err != nil {
+		return nil, err
+	}
+	if err := put("dropped6", m.Dropped6, net.IPv6len, false); err != nil {
+		return nil, err
+	}
+	return bencode.Encode(bencode.BnCode{State: bencode.BnDict, Value: dict})
+}
+
+// UnmarshalPex parses the message payload. Unknown keys are ignored.
+// Messages with more than PexMaxPeers peers in any of the lists are rejected, as BEP 11 requires.
+func UnmarshalPex(data []byte) (*PexMessage, error) {
+	node, n, err := bencode.DecodeBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, fmt.Errorf("Unexpected %d trailing bytes after the message", len(data)-n)
+	}
+	dict, err := node.GetDict()
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &PexMessage{}
+	if rc.Added, err = decodePeers(dict, "added", net.IPv4len); err != nil {
+		return nil, err
+	}
+	if err = decodeFlags(dict, "added.f", rc.Added); err != nil {
+		return nil, err
+	}
+	if rc.Dropped, err = decodePeers(dict, "dropped", net.IPv4len); err != nil {
+		return nil, err
+	}
+	if rc.Added6, err = decodePeers(dict, "added6", net.IPv6len); err != nil {
+		return nil, err
+	}
+	if err = decodeFlags(dict, "added6.f", rc.Added6); err != nil {
+		return nil, err
+	}
+	if rc.Dropped6, err = decodePeers(dict, "dropped6", net.IPv6len); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
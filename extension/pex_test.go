@@ -0,0 +1,173 @@
+package extension
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPexFlags(t *testing.T) {
+	f := PexFlags(0).Set(PexSeed|PexUTP, true)
+	if !f.Has(PexSeed) || !f.Has(PexUTP) || f.Has(PexEncryption) {
+		t.Errorf("Set() = %08b, want seed and uTP flags only", f)
+	}
+	if f = f.Set(PexSeed, false); f != PexUTP {
+		t.Errorf("Set() = %08b, want %08b", f, PexUTP)
+	}
+	if byte(PexReachable) != 0x10 {
+		t.Errorf("PexReachable = %#x, want 0x10", byte(PexReachable))
+	}
+}
+
+func TestUnmarshalPex(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *PexMessage
+		wantErr bool
+	}{
+		{
+			name: "Positive test case",
+			data: "d5:added12:\x0a\x00\x00\x01\x1a\xe1\x0a\x00\x00\x02\x00\x507:added.f2:\x12\x017:dropped6:\x0a\x00\x00\x03\x1a\xe1e",
+			want: &PexMessage{
+				Added: []PexPeer{
+					{IP: net.IP{10, 0, 0, 1}, Port: 6881, Flags: PexSeed | PexReachable},
+					{IP: net.IP{10, 0, 0, 2}, Port: 80, Flags: PexEncryption},
+				},
+				Dropped: []PexPeer{{IP: net.IP{10, 0, 0, 3}, Port: 6881}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing flags",
+			data: "d5:added6:\x0a\x00\x00\x01\x1a\xe1e",
+			want: &PexMessage{
+				Added: []PexPeer{{IP: net.IP{10, 0, 0, 1}, Port: 6881}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Empty message",
+			data:    "de",
+			want:    &PexMessage{},
+			wantErr: false,
+		},
+		{
+			name:    "Flags count mismatch",
+			data:    "d5:added6:\x0a\x00\x00\x01\x1a\xe17:added.f2:\x00\x00e",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Truncated IPv6 peer",
+			data:    "d6:added617:\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00e",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Too many added peers",
+			data:    "d5:added306:" + strings.Repeat("\x0a\x00\x00\x01\x1a\xe1", PexMaxPeers+1) + "e",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Too many dropped peers",
+			data:    "d8:dropped6918:" + strings.Repeat("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1a\xe1", PexMaxPeers+1) + "e",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Most peers allowed",
+			data: "d7:dropped300:" + strings.Repeat("\x0a\x00\x00\x01\x1a\xe1", PexMaxPeers) + "e",
+			want: &PexMessage{
+				Dropped: func() []PexPeer {
+					rc := make([]PexPeer, PexMaxPeers)
+					for i := range rc {
+						rc[i] = PexPeer{IP: net.IP{10, 0, 0, 1}, Port: 6881}
+					}
+					return rc
+				}(),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalPex([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalPex() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalPex() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPexMessage_RoundTrip(t *testing.T) {
+	msg := &PexMessage{
+		Added:    []PexPeer{{IP: net.IP{192, 168, 1, 1}, Port: 51413, Flags: PexUTP | PexHolepunch}},
+		Dropped:  []PexPeer{{IP: net.IP{192, 168, 1, 2}, Port: 6881}},
+		Added6:   []PexPeer{{IP: net.ParseIP("2001:db8::1"), Port: 6881, Flags: PexReachable}},
+		Dropped6: []PexPeer{{IP: net.ParseIP("2001:db8::2"), Port: 6882}},
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("PexMessage.Marshal() error = %v", err)
+	}
+	got, err := UnmarshalPex(data)
+	if err != nil {
+		t.Fatalf("UnmarshalPex() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Errorf("UnmarshalPex() = %#v, want %#v", got, msg)
+	}
+}
+
+func TestPexMessage_Marshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     PexMessage
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Added peer with flags",
+			msg:     PexMessage{Added: []PexPeer{{IP: net.IPv4(10, 0, 0, 1), Port: 6881, Flags: PexSeed}}},
+			want:    "d5:added6:\x0a\x00\x00\x01\x1a\xe17:added.f1:\x02e",
+			wantErr: false,
+		},
+		{
+			name:    "IPv6 peer in the IPv4 list",
+			msg:     PexMessage{Added: []PexPeer{{IP: net.ParseIP("::1"), Port: 1}}},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "IPv4 peer in the IPv6 list",
+			msg:     PexMessage{Dropped6: []PexPeer{{IP: net.IPv4(10, 0, 0, 1), Port: 1}}},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Too many peers",
+			msg:     PexMessage{Dropped: make([]PexPeer, PexMaxPeers+1)},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.msg.Marshal()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PexMessage.Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("PexMessage.Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+// Package fastresume reads and writes libtorrent .fastresume files.
+//
+// The well known keys are exposed as typed fields, everything else is kept in Extra,
+// so a file that is decoded and encoded again without changes is reproduced byte for byte,
+// including the order of its keys when they are not sorted.
+package fastresume
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"bencode"
+)
+
+// FileFormat is the value of the "file-format" key written by libtorrent
+const FileFormat = "libtorrent resume file"
+
+// Peer is an address from the "peers" or "peers6" compact lists.
+type Peer struct {
+	IP   net.IP
+	Port int
+}
+
+// ResumeData is the content of a single .fastresume file.
+//
+// Known keys that were present in the decoded file are always written back,
+// the rest of them are only written when their field is not empty.
+type ResumeData struct {
+	FileFormat  string
+	FileVersion int
+	// InfoHash is the raw 20 byte v1 info hash, "info-hash" key
+	InfoHash string
+	Name     string
+	SavePath string
+	// Pieces holds one byte per piece, the lowest bit tells that the piece is downloaded
+	Pieces []byte
+	// MappedFiles are the renamed file paths, indexed by the file index in the torrent
+	MappedFiles []string
+	// Trackers are the announce urls grouped by tier
+	Trackers [][]string
+	Peers    []Peer
+	Peers6   []Peer
+	// AddedTime and CompletedTime are stored as unix timestamps, 0 maps to the zero time
+	AddedTime     time.Time
+	CompletedTime time.Time
+
+	// Extra holds all the keys that do not have a field of their own.
+	// Dictionaries read from a file are OrderedDict values, which keeps the order of their keys.
+	Extra map[string]bencode.BnCode
+
+	// present records the known keys found in the decoded file
+	present map[string]bool
+	// order is the key order of the decoded file, nil unless the keys were not sorted
+	order []string
+}
+
+var knownKeys = map[string]bool{
+	"file-format": true, "file-version": true, "info-hash": true, "name": true, "save_path": true,
+	"pieces": true, "mapped_files": true, "trackers": true, "peers": true, "peers6": true,
+	"added_time": true, "completed_time": true,
+}
+
+// Have tells whether the piece at the given index is downloaded.
+func (r *ResumeData) Have(piece int) bool {
+	return piece >= 0 && piece < len(r.Pieces) && r.Pieces[piece]&1 != 0
+}
+
+// NumHave returns the number of downloaded pieces.
+func (r *ResumeData) NumHave() int {
+	rc := 0
+	for i := range r.Pieces {
+		if r.Have(i) {
+			rc++
+		}
+	}
+	return rc
+}
+
+func str(s string) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnString, Value: s}
+}
+
+func integer(i int) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnInt, Value: i}
+}
+
+func stringList(list []string) bencode.BnCode {
+	rc := make([]bencode.BnCode, len(list))
+	for i, s := range list {
+		rc[i] = str(s)
+	}
+	return bencode.BnCode{State: bencode.BnList, Value: rc}
+}
+
+func encodePeers(peers []Peer, ipLen int) (string, error) {
+	size := ipLen + 2
+	rc := make([]byte, len(peers)*size)
+	for i, p := range peers {
+		ip := p.IP.To16()
+		if ipLen == net.IPv4len {
+			ip = p.IP.To4()
+		}
+		if ip == nil {
+			return "", fmt.Errorf("Peer %v does not match the address family of the list", p.IP)
+		}
+		if p.Port < 0 || p.Port > 0xffff {
+			return "", fmt.Errorf("Port %d is out of range", p.Port)
+		}
+		copy(rc[i*size:], ip)
+		binary.BigEndian.PutUint16(rc[i*size+ipLen:], uint16(p.Port))
+	}
+	return string(rc), nil
+}
+
+func decodePeers(src string, ipLen int) ([]Peer, error) {
+	size := ipLen + 2
+	if len(src)%size != 0 {
+		return nil, fmt.Errorf("Compact peers length %d is not a multiple of %d", len(src), size)
+	}
+	rc := make([]Peer, len(src)/size)
+	for i := range rc {
+		entry := []byte(src[i*size : (i+1)*size])
+		rc[i] = Peer{IP: net.IP(entry[:ipLen]), Port: int(binary.BigEndian.Uint16(entry[ipLen:]))}
+	}
+	return rc, nil
+}
+
+func unixTime(t time.Time) int {
+	if t.IsZero() {
+		return 0
+	}
+	return int(t.Unix())
+}
+
+// Marshal encodes the resume data.
+func (r *ResumeData) Marshal() ([]byte, error) {
+	dict := make(map[string]bencode.BnCode, len(r.Extra)+len(knownKeys))
+	for k, v := range r.Extra {
+		if knownKeys[k] {
+			return nil, fmt.Errorf("Key %q has a field of its own and can not be set in Extra", k)
+		}
+		dict[k] = v
+	}
+	keep := func(key string, nonEmpty bool) bool {
+		return nonEmpty || r.present[key]
+	}
+
+	if keep("file-format", r.FileFormat != "") {
+		dict["file-format"] = str(r.FileFormat)
+	}
+	if keep("file-version", r.FileVersion != 0) {
+		dict["file-version"] = integer(r.FileVersion)
+	}
+	if keep("info-hash", r.InfoHash != "") {
+		dict["info-hash"] = str(r.InfoHash)
+	}
+	if keep("name", r.Name != "") {
+		dict["name"] = str(r.Name)
+	}
+	if keep("save_path", r.SavePath != "") {
+		dict["save_path"] = str(r.SavePath)
+	}
+	if keep("pieces", len(r.Pieces) > 0) {
+		dict["pieces"] = str(string(r.Pieces))
+	}
+	if keep("mapped_files", len(r.MappedFiles) > 0) {
+		dict["mapped_files"] = stringList(r.MappedFiles)
+	}
+	if keep("trackers", len(r.Trackers) > 0) {
+		tiers := make([]bencode.BnCode, len(r.Trackers))
+		for i, tier := range r.Trackers {
+			tiers[i] = stringList(tier)
+		}
+		dict["trackers"] = bencode.BnCode{State: bencode.BnList, Value: tiers}
+	}
+	if keep("peers", len(r.Peers) > 0) {
+		peers, err := encodePeers(r.Peers, net.IPv4len)
+		if err != nil {
+			return nil, err
+		}
+		dict["peers"] = str(peers)
+	}
+	if keep("peers6", len(r.Peers6) > 0) {
+		peers, err := encodePeers(r.Peers6, net.IPv6len)
+		if err != nil {
+			return nil, err
+		}
+		dict["peers6"] = str(peers)
+	}
+	if keep("added_time", !r.AddedTime.IsZero()) {
+		dict["added_time"] = integer(unixTime(r.AddedTime))
+	}
+	if keep("completed_time", !r.CompletedTime.IsZero()) {
+		dict["completed_time"] = integer(unixTime(r.CompletedTime))
+	}
+
+	if r.order == nil {
+		return bencode.Encode(bencode.BnCode{State: bencode.BnDict, Value: dict})
+	}
+	// the keys of the decoded file come first in their original order, the new ones follow sorted
+	entries := make(bencode.OrderedDict, 0, len(dict))
+	for _, k := range r.order {
+		if v, ok := dict[k]; ok {
+			entries = append(entries, bencode.DictEntry{Key: k, Value: v})
+			delete(dict, k)
+		}
+	}
+	added := make([]string, 0, len(dict))
+	for k := range dict {
+		added = append(added, k)
+	}
+	sort.Strings(added)
+	for _, k := range added {
+		entries = append(entries, bencode.DictEntry{Key: k, Value: dict[k]})
+	}
+	return bencode.Encode(bencode.BnCode{State: bencode.BnDict, Value: entries})
+}
+
+// Unmarshal parses the content of a .fastresume file.
+//
+// Keys are not required to be sorted, their order is kept for Marshal. Of duplicate keys the last one is kept.
+func Unmarshal(data []byte) (*ResumeData, error) {
+	reader := bytes.NewReader(data)
+	decoder := bencode.NewDecoder(reader)
+	decoder.PreserveOrder()
+	node, err := decoder.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("Unexpected %d trailing bytes after the resume data", reader.Len())
+	}
+	entries, err := node.GetOrderedDict()
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &ResumeData{Extra: make(map[string]bencode.BnCode), present: make(map[string]bool)}
+	order := make([]string, 0, len(entries))
+	sorted := true
+	for i, entry := range entries {
+		k, v := entry.Key, entry.Value
+		if i > 0 && entries[i-1].Key >= k {
+			sorted = false
+		}
+		if _, seen := rc.Extra[k]; !seen && !rc.present[k] {
+			order = append(order, k)
+		}
+		if !knownKeys[k] {
+			rc.Extra[k] = v
+			continue
+		}
+		rc.present[k] = true
+		if err = rc.parseKey(k, v); err != nil {
+			return nil, fmt.Errorf("Key %q: %v", k, err)
+		}
+	}
+	if !sorted {
+		rc.order = order
+	}
+	return rc, nil
+}
+
+func (r *ResumeData) parseKey(key string, val bencode.BnCode) error {
+	var err error
+	var s string
+	var i int
+
+	switch key {
+	case "file-format":
+		r.FileFormat, err = val.GetString()
+	case "file-version":
+		r.FileVersion, err = val.GetInt()
+	case "info-hash":
+		r.InfoHash, err = val.GetString()
+	case "name":
+		r.Name, err = val.GetString()
+	case "save_path":
+		r.SavePath, err = val.GetString()
+	case "pieces":
+		if s, err = val.GetString(); err == nil {
+			r.Pieces = []byte(s)
+		}
+	case "mapped_files":
+		r.MappedFiles, err = parseStringList(val)
+	case "trackers":
+		var tiers []bencode.BnCode
+		if tiers, err = val.GetList(); err != nil {
+			return err
+		}
+		r.Trackers = make([][]string, len(tiers))
+		for n, tier := range tiers {
+			if r.Trackers[n], err = parseStringList(tier); err != nil {
+				return err
+			}
+		}
+	case "peers":
+		if s, err = val.GetString(); err == nil {
+			r.Peers, err = decodePeers(s, net.IPv4len)
+		}
+	case "peers6":
+		if s, err = val.GetString(); err == nil {
+			r.Peers6, err = decodePeers(s, net.IPv6len)
+		}
+	case "added_time":
+		if i, err = val.GetInt(); err == nil && i != 0 {
+			r.AddedTime = time.Unix(int64(i), 0)
+		}
+	case "completed_time":
+		if i, err = val.GetInt(); err == nil && i != 0 {
+			r.CompletedTime = time.Unix(int64(i), 0)
+		}
+	}
+	return err
+}
+
+func parseStringList(val bencode.BnCode) ([]string, error) {
+	list, err := val.GetList()
+	if err != nil {
+		return nil, err
+	}
+	rc := make([]string, len(list))
+	for i, v := range list {
+		if rc[i], err = v.GetString(); err != nil {
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// ReadFile reads and parses the .fastresume file at the given path.
+func ReadFile(path string) (*ResumeData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(data)
+}
+
+// WriteFile encodes the resume data and writes it to the given path.
+func (r *ResumeData) WriteFile(path string) error {
+	data, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,158 @@
+package fastresume
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bencode"
+)
+
+const sample = "d10:added_timei1600000000e14:completed_timei0e11:file-format22:libtorrent resume file" +
+	"12:file-versioni1e9:info-hash20:aaaaaaaaaaaaaaaaaaaa18:libtorrent-version7:1.2.1.012:mapped_filesl0:9:other.isoe" +
+	"4:name6:ubuntu6:pausedi0e5:peers12:\x0a\x00\x00\x01\x1a\xe1\x0a\x00\x00\x02\x1a\xe26:pieces4:\x01\x00\x01\x03" +
+	"9:save_path15:/data/downloads8:trackersll11:http://a/an11:http://b/anel11:http://c/aneee"
+
+func TestUnmarshal(t *testing.T) {
+	got, err := Unmarshal([]byte(sample))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := &ResumeData{
+		FileFormat:    FileFormat,
+		FileVersion:   1,
+		InfoHash:      strings.Repeat("a", 20),
+		Name:          "ubuntu",
+		SavePath:      "/data/downloads",
+		Pieces:        []byte{1, 0, 1, 3},
+		MappedFiles:   []string{"", "other.iso"},
+		Trackers:      [][]string{{"http://a/an", "http://b/an"}, {"http://c/an"}},
+		Peers:         []Peer{{IP: net.IP{10, 0, 0, 1}, Port: 6881}, {IP: net.IP{10, 0, 0, 2}, Port: 6882}},
+		AddedTime:     time.Unix(1600000000, 0),
+		CompletedTime: time.Time{},
+		Extra: map[string]bencode.BnCode{
+			"libtorrent-version": {State: bencode.BnString, Value: "1.2.1.0"},
+			"paused":             {State: bencode.BnInt, Value: 0},
+		},
+	}
+	got.present = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %#v, want %#v", got, want)
+	}
+	if got.NumHave() != 3 || got.Have(1) || !got.Have(3) {
+		t.Errorf("NumHave() = %d, want 3", got.NumHave())
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r, err := Unmarshal([]byte(sample))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	got, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	// completed_time is zero, it is written back only because it was present
+	if string(got) != sample {
+		t.Errorf("Marshal() = %q, want %q", got, sample)
+	}
+}
+
+func TestRoundTrip_order(t *testing.T) {
+	// libtorrent versions wrote the keys in the order of their struct fields
+	data := "d4:name1:x11:file-format22:libtorrent resume file5:extrad1:zi1e1:ai2ee6:pausedi0ee"
+	r, err := Unmarshal([]byte(data))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	got, err := r.Marshal()
+	if err != nil || string(got) != data {
+		t.Errorf("Marshal() = %q, %v, want %q", got, err, data)
+	}
+	extra := r.Extra["extra"]
+	if dict, err := extra.GetDict(); err != nil || dict["a"].Value != 2 {
+		t.Errorf("GetDict() = %v, %v", dict, err)
+	}
+
+	// new keys follow the original ones
+	r.SavePath = "/x"
+	r.Extra["added"] = bencode.BnCode{State: bencode.BnInt, Value: 1}
+	want := data[:len(data)-1] + "5:addedi1e9:save_path2:/xe"
+	if got, err = r.Marshal(); err != nil || string(got) != want {
+		t.Errorf("Marshal() = %q, %v, want %q", got, err, want)
+	}
+
+	// duplicate keys keep the last value at the first position
+	r, err = Unmarshal([]byte("d1:bi1e1:ai2e1:bi3ee"))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got, err = r.Marshal(); err != nil || string(got) != "d1:bi3e1:ai2ee" {
+		t.Errorf("Marshal() = %q, %v, want %q", got, err, "d1:bi3e1:ai2ee")
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Not a dictionary", data: "le"},
+		{name: "Wrong type of a known key", data: "d6:piecesi1ee"},
+		{name: "Truncated peers", data: "d5:peers5:abcdee"},
+		{name: "Trailing data", data: "dei1e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unmarshal([]byte(tt.data)); err == nil {
+				t.Errorf("Unmarshal() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestMarshalNew(t *testing.T) {
+	r := &ResumeData{FileFormat: FileFormat, SavePath: "/x", Extra: map[string]bencode.BnCode{
+		"paused": {State: bencode.BnInt, Value: 1},
+	}}
+	got, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "d11:file-format22:libtorrent resume file6:pausedi1e9:save_path2:/xe"
+	if string(got) != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+
+	r.Extra["save_path"] = bencode.BnCode{State: bencode.BnString, Value: "/y"}
+	if _, err = r.Marshal(); err == nil {
+		t.Errorf("Marshal() accepted a known key in Extra")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "fastresume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "x.fastresume")
+	r := &ResumeData{Name: "x", Pieces: []byte{1}}
+	if err = r.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if got.Name != "x" || !got.Have(0) {
+		t.Errorf("ReadFile() = %#v", got)
+	}
+}
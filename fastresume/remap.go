@@ -0,0 +1,82 @@
+package fastresume
+
+import (
+	"sort"
+	"strings"
+)
+
+// PathMapping replaces the From prefix of a path with To.
+type PathMapping struct {
+	From string
+	To   string
+}
+
+func isSeparator(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+// remap applies the first mapping, which are expected to be sorted longest prefix first,
+// that matches the whole path or its leading directories.
+func remap(path string, mappings []PathMapping) (string, bool) {
+	for _, m := range mappings {
+		from := strings.TrimRight(m.From, "/\\")
+		if !strings.HasPrefix(path, from) {
+			continue
+		}
+		rest := path[len(from):]
+		if rest != "" && !isSeparator(rest[0]) {
+			// "/data" must not match "/database"
+			continue
+		}
+		return strings.TrimRight(m.To, "/\\") + rest, true
+	}
+	return path, false
+}
+
+func sortMappings(mappings []PathMapping) []PathMapping {
+	rc := make([]PathMapping, len(mappings))
+	copy(rc, mappings)
+	sort.SliceStable(rc, func(i, j int) bool {
+		return len(strings.TrimRight(rc[i].From, "/\\")) > len(strings.TrimRight(rc[j].From, "/\\"))
+	})
+	return rc
+}
+
+// RemapSavePath rewrites the save path, as well as the absolute mapped files,
+// with the longest matching mapping. Prefixes only match whole path components.
+//
+// Returns true if anything was changed.
+func (r *ResumeData) RemapSavePath(mappings ...PathMapping) bool {
+	return r.remap(sortMappings(mappings))
+}
+
+func (r *ResumeData) remap(mappings []PathMapping) bool {
+	var changed, ok bool
+	if r.SavePath, ok = remap(r.SavePath, mappings); ok {
+		changed = true
+	}
+	for i, f := range r.MappedFiles {
+		if f == "" || !(isSeparator(f[0]) || strings.Contains(f, ":")) {
+			// relative to the save path, moved along with it
+			continue
+		}
+		if r.MappedFiles[i], ok = remap(f, mappings); ok {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// RemapSavePaths applies RemapSavePath to every resume data.
+//
+// Returns the number of resume data that were changed.
+func RemapSavePaths(files []*ResumeData, mappings ...PathMapping) int {
+	sorted := sortMappings(mappings)
+	rc := 0
+	for _, f := range files {
+		if f.remap(sorted) {
+			rc++
+		}
+	}
+	return rc
+}
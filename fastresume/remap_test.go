@@ -0,0 +1,55 @@
+package fastresume
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_remap(t *testing.T) {
+	mappings := sortMappings([]PathMapping{
+		{From: "/data", To: "/mnt/a"},
+		{From: "/data/tv/", To: "/mnt/tv"},
+		{From: `C:\Torrents`, To: "/mnt/win"},
+	})
+	tests := []struct {
+		name   string
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{name: "Exact match", path: "/data", want: "/mnt/a", wantOk: true},
+		{name: "Nested directory", path: "/data/movies", want: "/mnt/a/movies", wantOk: true},
+		{name: "Longest prefix wins", path: "/data/tv/show", want: "/mnt/tv/show", wantOk: true},
+		{name: "Partial component", path: "/database", want: "/database", wantOk: false},
+		{name: "Windows path", path: `C:\Torrents\linux`, want: `/mnt/win\linux`, wantOk: true},
+		{name: "No match", path: "/srv", want: "/srv", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := remap(tt.path, mappings)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("remap() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRemapSavePaths(t *testing.T) {
+	files := []*ResumeData{
+		{SavePath: "/data/a", MappedFiles: []string{"", "renamed.iso", "/data/elsewhere/b.iso"}},
+		{SavePath: "/srv/b"},
+		{SavePath: "/data"},
+	}
+	if got := RemapSavePaths(files, PathMapping{From: "/data", To: "/mnt/data"}); got != 2 {
+		t.Errorf("RemapSavePaths() = %d, want 2", got)
+	}
+
+	want := []*ResumeData{
+		{SavePath: "/mnt/data/a", MappedFiles: []string{"", "renamed.iso", "/mnt/data/elsewhere/b.iso"}},
+		{SavePath: "/srv/b"},
+		{SavePath: "/mnt/data"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("RemapSavePaths() left %v, want %v", files, want)
+	}
+}
@@ -0,0 +1,58 @@
+package bencode
+
+import (
+	"os"
+)
+
+// File is a bencoded file opened with OpenFile. The content is accessed through
+// the lazy View machinery, so only the pages that are actually read are loaded.
+//
+// Views obtained from the file, as well as slices returned by them, point into the mapping.
+// Using them after Close crashes the program, see OpenFile.
+type File struct {
+	root View
+	data []byte
+}
+
+// OpenFile maps the file at the given path into memory and validates it with Parse.
+// On platforms without mmap support the file is read into memory instead.
+//
+// Nothing returned by the file, neither Views nor the strings and slices obtained from them,
+// may be used after Close: the memory is unmapped and reading it is a segmentation fault that can not
+// be recovered. Copy what has to outlive the file, for example with View.Decode. The mapping is private,
+// so later writes to the file are not guaranteed to be seen, but the file must not shrink while it is open:
+// reading the pages past the new end is a bus error. Use os.ReadFile and Parse for files that could change.
+func OpenFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := mapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	root, err := Parse(data)
+	if err != nil {
+		unmapFile(data)
+		return nil, err
+	}
+	return &File{root: root, data: data}, nil
+}
+
+// Root returns the View of the top level node of the file.
+func (f *File) Root() View {
+	return f.root
+}
+
+// Close releases the mapping, which invalidates every View and slice obtained from the file.
+// It is safe to call Close multiple times.
+func (f *File) Close() error {
+	if f.data == nil {
+		return nil
+	}
+	data := f.data
+	f.data, f.root = nil, View{}
+	return unmapFile(data)
+}
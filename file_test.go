@@ -0,0 +1,71 @@
+package bencode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemp(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "bencode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.torrent")
+	if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestOpenFile(t *testing.T) {
+	path, cleanup := writeTemp(t, viewSample)
+	defer cleanup()
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	name, err := f.Root().Path("info", "name")
+	if err != nil {
+		t.Fatalf("View.Path() error = %v", err)
+	}
+	if s, err := name.Str(); err != nil || s != "ubuntu.iso" {
+		t.Errorf("View.Str() = %q, %v, want %q", s, err, "ubuntu.iso")
+	}
+
+	if err = f.Close(); err != nil {
+		t.Errorf("File.Close() error = %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Errorf("second File.Close() error = %v", err)
+	}
+	if _, err = f.Root().Get("info"); err == nil {
+		t.Errorf("View.Get() succeeded after Close")
+	}
+}
+
+func TestOpenFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Empty file", content: ""},
+		{name: "Invalid content", content: "d1:a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := writeTemp(t, tt.content)
+			defer cleanup()
+			if _, err := OpenFile(path); err == nil {
+				t.Errorf("OpenFile() succeeded, want error")
+			}
+		})
+	}
+
+	if _, err := OpenFile(filepath.Join(os.TempDir(), "does-not-exist.torrent")); err == nil {
+		t.Errorf("OpenFile() opened a missing file")
+	}
+}
@@ -0,0 +1,251 @@
+// Package framing sends bencoded messages over stream connections such as TCP.
+//
+// Every message is preceded by its length as a 4 byte big-endian integer, so the receiver knows
+// where a message ends before decoding it and can reject oversized ones without buffering them.
+package framing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"bencode"
+)
+
+const (
+	// HeaderSize is the length of the big-endian size prefix of every frame
+	HeaderSize = 4
+	// DefaultMaxFrameSize is the largest message accepted or produced unless set otherwise
+	DefaultMaxFrameSize = 1 << 20
+	// maxRetainedBuffer is the largest read or write buffer kept for the next message
+	maxRetainedBuffer = 64 << 10
+)
+
+// FrameTooLargeError is returned for messages whose encoded size exceeds the frame size limit.
+type FrameTooLargeError struct {
+	// Size is int64 as the size announced by a frame header does not fit into an int on 32-bit platforms
+	Size  int64
+	Limit int
+}
+
+func (e FrameTooLargeError) Error() string {
+	return fmt.Sprintf("Frame of %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
+}
+
+// Conn reads and writes length-prefixed bencoded messages on a connection.
+//
+// WriteMessage is safe for concurrent use, ReadMessage must be called from a single goroutine.
+// Use the option methods before the first message.
+type Conn struct {
+	conn         net.Conn
+	maxFrameSize int
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	decoder *bencode.Decoder
+	frame   []byte
+	// readErr is the error that left the stream in the middle of a frame
+	readErr error
+	// skip is how much of an oversized frame is left to discard before the next frame
+	skip     int64
+	writeMu  sync.Mutex
+	writeBuf bytes.Buffer
+}
+
+// NewConn creates a message connection on top of the given connection.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, maxFrameSize: DefaultMaxFrameSize, decoder: bencode.NewDecoder(nil)}
+}
+
+// MaxFrameSize limits the encoded size of messages in both directions, non-positive values restore the default.
+func (c *Conn) MaxFrameSize(n int) {
+	if n <= 0 {
+		n = DefaultMaxFrameSize
+	}
+	c.maxFrameSize = n
+}
+
+// ReadTimeout sets how long ReadMessage waits for a whole message, zero waits forever.
+// A timeout in the middle of a frame leaves the connection unusable, see ReadMessage.
+func (c *Conn) ReadTimeout(d time.Duration) {
+	c.readTimeout = d
+}
+
+// WriteTimeout sets how long WriteMessage waits for a message to be sent, zero waits forever.
+func (c *Conn) WriteTimeout(d time.Duration) {
+	c.writeTimeout = d
+}
+
+// Decoder returns the decoder used for incoming messages, to set its options before the first call to ReadMessage.
+func (c *Conn) Decoder() *bencode.Decoder {
+	return c.decoder
+}
+
+// Conn returns the underlying connection.
+func (c *Conn) Conn() net.Conn {
+	return c.conn
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage reads and decodes the next message.
+//
+// It returns io.EOF if the connection was closed between messages and FrameTooLargeError
+// if the announced size exceeds the limit. The oversized frame is not buffered: either close the connection
+// or call ReadMessage again, which discards the frame before reading the next one. Other errors that happen
+// after the first byte of a frame was read, including timeouts, leave the stream out of sync, so ReadMessage
+// keeps returning them and the connection should be closed. A timeout before the frame starts or while
+// discarding an oversized frame and a frame that is read whole but fails to decode do not prevent reading
+// the following messages.
+func (c *Conn) ReadMessage() (bencode.BnCode, error) {
+	if c.readErr != nil {
+		return bencode.BnCode{}, c.readErr
+	}
+	if c.readTimeout > 0 {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return bencode.BnCode{}, err
+		}
+	}
+	if c.skip > 0 {
+		n, err := io.CopyN(io.Discard, c.conn, c.skip)
+		c.skip -= n
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return bencode.BnCode{}, err
+		}
+	}
+
+	var header [HeaderSize]byte
+	if n, err := io.ReadFull(c.conn, header[:]); err != nil {
+		if n > 0 {
+			c.readErr = err
+		}
+		return bencode.BnCode{}, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	// compared before converting to int, which could overflow on 32-bit platforms
+	if int64(size) > int64(c.maxFrameSize) {
+		c.skip = int64(size)
+		return bencode.BnCode{}, FrameTooLargeError{Size: int64(size), Limit: c.maxFrameSize}
+	}
+	if size == 0 {
+		return bencode.BnCode{}, fmt.Errorf("Frame is empty")
+	}
+
+	n := int(size)
+	if cap(c.frame) < n {
+		c.frame = make([]byte, n)
+	}
+	defer func() {
+		// do not hold on to the memory of a large message
+		if cap(c.frame) > maxRetainedBuffer {
+			c.frame = nil
+		}
+	}()
+	frame := c.frame[:n]
+	if _, err := io.ReadFull(c.conn, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		c.readErr = err
+		return bencode.BnCode{}, err
+	}
+
+	reader := bytes.NewReader(frame)
+	c.decoder.Reset(reader)
+	node, err := c.decoder.Decode()
+	c.decoder.Reset(nil)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return bencode.BnCode{}, fmt.Errorf("Invalid frame: %v", err)
+	}
+	if reader.Len() != 0 {
+		return bencode.BnCode{}, fmt.Errorf("Unexpected %d trailing bytes after the message", reader.Len())
+	}
+	return node, nil
+}
+
+// WriteMessage encodes the message and writes it as a single frame.
+//
+// The size of the message is counted before encoding it, so nothing is buffered or written if it exceeds
+// the limit, in which case FrameTooLargeError is returned. Nothing is written if the message can not be encoded.
+func (c *Conn) WriteMessage(node bencode.BnCode) error {
+	size := encodedSize(node)
+	if size > c.maxFrameSize {
+		return FrameTooLargeError{Size: int64(size), Limit: c.maxFrameSize}
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	defer func() {
+		// do not hold on to the memory of a large message
+		if c.writeBuf.Cap() > maxRetainedBuffer {
+			c.writeBuf = bytes.Buffer{}
+		}
+	}()
+
+	c.writeBuf.Reset()
+	c.writeBuf.Grow(HeaderSize + size)
+	c.writeBuf.Write(make([]byte, HeaderSize))
+	if err := bencode.NewEncoder(&c.writeBuf).Encode(node); err != nil {
+		return err
+	}
+	frame := c.writeBuf.Bytes()
+	if size = len(frame) - HeaderSize; size > c.maxFrameSize {
+		return FrameTooLargeError{Size: int64(size), Limit: c.maxFrameSize}
+	}
+	binary.BigEndian.PutUint32(frame, uint32(size))
+
+	if c.writeTimeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// encodedSize counts the bytes of the encoding of the node without encoding it.
+// Values that can not be encoded count as empty, the encoder reports them.
+func encodedSize(node bencode.BnCode) int {
+	var scratch [20]byte
+	switch v := node.Value.(type) {
+	case int:
+		return len(strconv.AppendInt(scratch[:0], int64(v), 10)) + 2
+	case string:
+		return len(strconv.AppendInt(scratch[:0], int64(len(v)), 10)) + 1 + len(v)
+	case bencode.StreamedString:
+		return len(strconv.AppendInt(scratch[:0], int64(v.Length), 10)) + 1 + v.Length
+	case []bencode.BnCode:
+		size := 2
+		for _, elem := range v {
+			size += encodedSize(elem)
+		}
+		return size
+	case map[string]bencode.BnCode:
+		size := 2
+		for k, elem := range v {
+			size += encodedSize(bencode.BnCode{State: bencode.BnString, Value: k}) + encodedSize(elem)
+		}
+		return size
+	case bencode.OrderedDict:
+		size := 2
+		for _, entry := range v {
+			size += encodedSize(bencode.BnCode{State: bencode.BnString, Value: entry.Key}) + encodedSize(entry.Value)
+		}
+		return size
+	}
+	return 0
+}
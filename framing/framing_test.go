@@ -0,0 +1,297 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bencode"
+)
+
+func str(s string) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnString, Value: s}
+}
+
+func dict(key string, val bencode.BnCode) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnDict, Value: map[string]bencode.BnCode{key: val}}
+}
+
+func TestConn_RoundTrip(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	messages := []bencode.BnCode{
+		dict("a", str("hello")),
+		{State: bencode.BnInt, Value: 42},
+		dict("b", str(strings.Repeat("x", 100000))),
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		c := NewConn(conn)
+		defer c.Close()
+		for _, m := range messages {
+			if err := c.WriteMessage(m); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConn(conn)
+	defer c.Close()
+	c.ReadTimeout(5 * time.Second)
+	for i, want := range messages {
+		got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("Conn.ReadMessage() #%d error = %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Conn.ReadMessage() #%d = %v, want %v", i, got, want)
+		}
+	}
+	if _, err := c.ReadMessage(); err != io.EOF {
+		t.Errorf("Conn.ReadMessage() error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestConn_ReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    bencode.BnCode
+		wantErr string
+	}{
+		{
+			name: "Valid frame",
+			data: "\x00\x00\x00\x08d1:a1:be",
+			want: dict("a", str("b")),
+		},
+		{
+			name:    "Frame too large",
+			data:    "\x00\x00\x01\x00",
+			wantErr: "Frame of 256 bytes exceeds the limit of 64 bytes",
+		},
+		{
+			name:    "Largest frame size",
+			data:    "\xff\xff\xff\xff",
+			wantErr: "Frame of 4294967295 bytes exceeds the limit of 64 bytes",
+		},
+		{
+			name:    "Empty frame",
+			data:    "\x00\x00\x00\x00",
+			wantErr: "Frame is empty",
+		},
+		{
+			name:    "Truncated frame",
+			data:    "\x00\x00\x00\x08d1:a",
+			wantErr: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:    "Truncated header",
+			data:    "\x00\x00",
+			wantErr: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:    "Truncated message",
+			data:    "\x00\x00\x00\x04d1:a",
+			wantErr: "Invalid frame: unexpected EOF",
+		},
+		{
+			name:    "Trailing bytes",
+			data:    "\x00\x00\x00\x05i1ei2",
+			wantErr: "Unexpected 2 trailing bytes after the message",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				server.Write([]byte(tt.data))
+				server.Close()
+			}()
+
+			c := NewConn(client)
+			c.MaxFrameSize(64)
+			got, err := c.ReadMessage()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Conn.ReadMessage() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Conn.ReadMessage() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Conn.ReadMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConn_WriteMessage(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	c := NewConn(client)
+	c.MaxFrameSize(16)
+	err := c.WriteMessage(str(strings.Repeat("x", 16)))
+	if _, ok := err.(FrameTooLargeError); !ok {
+		t.Errorf("Conn.WriteMessage() error = %v, want FrameTooLargeError", err)
+	}
+	if c.writeBuf.Cap() != 0 {
+		t.Errorf("Conn.WriteMessage() buffered %d bytes of a message too large", c.writeBuf.Cap())
+	}
+	if err = c.WriteMessage(bencode.BnCode{State: bencode.BnInt, Value: "x"}); err == nil {
+		t.Errorf("Conn.WriteMessage() accepted an invalid node")
+	}
+
+	done := make(chan []byte)
+	go func() {
+		buf := make([]byte, 9)
+		n, _ := io.ReadFull(server, buf)
+		done <- buf[:n]
+	}()
+	if err = c.WriteMessage(str("abc")); err != nil {
+		t.Fatalf("Conn.WriteMessage() error = %v", err)
+	}
+	if got, want := string(<-done), "\x00\x00\x00\x053:abc"; got != want {
+		t.Errorf("Conn.WriteMessage() wrote %q, want %q", got, want)
+	}
+}
+
+func TestConn_Timeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	c := NewConn(client)
+	c.ReadTimeout(10 * time.Millisecond)
+	c.WriteTimeout(10 * time.Millisecond)
+
+	_, err := c.ReadMessage()
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("Conn.ReadMessage() error = %v, want a timeout", err)
+	}
+	err = c.WriteMessage(str("abc"))
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("Conn.WriteMessage() error = %v, want a timeout", err)
+	}
+}
+
+func Test_encodedSize(t *testing.T) {
+	tests := []struct {
+		name string
+		node bencode.BnCode
+	}{
+		{name: "Negative int", node: bencode.BnCode{State: bencode.BnInt, Value: -1234}},
+		{name: "String", node: str(strings.Repeat("x", 123))},
+		{name: "Streamed string", node: bencode.BnCode{State: bencode.BnString, Value: bencode.StreamedString{Length: 10, Reader: strings.NewReader("0123456789")}}},
+		{name: "Nested", node: dict("a", bencode.BnCode{State: bencode.BnList, Value: []bencode.BnCode{str(""), dict("bb", str("c"))}})},
+		{name: "Ordered dict", node: bencode.BnCode{State: bencode.BnDict, Value: bencode.OrderedDict{{Key: "z", Value: str("1")}, {Key: "a", Value: str("2")}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := bencode.NewEncoder(&buf).Encode(tt.node); err != nil {
+				t.Fatalf("Encoder.Encode() error = %v", err)
+			}
+			if got := encodedSize(tt.node); got != buf.Len() {
+				t.Errorf("encodedSize() = %d, want %d", got, buf.Len())
+			}
+		})
+	}
+}
+
+func TestConn_ReadMessage_timeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	c := NewConn(client)
+	c.ReadTimeout(20 * time.Millisecond)
+
+	// nothing of the frame was read, the next message can still be read
+	if _, err := c.ReadMessage(); err == nil {
+		t.Fatalf("Conn.ReadMessage() error = nil, want a timeout")
+	}
+	go server.Write([]byte("\x00\x00\x00\x03i1e"))
+	if got, err := c.ReadMessage(); err != nil || !reflect.DeepEqual(got, bencode.BnCode{State: bencode.BnInt, Value: 1}) {
+		t.Fatalf("Conn.ReadMessage() = %v, %v, want i1e", got, err)
+	}
+
+	// the timeout hits in the middle of the frame, which leaves the stream out of sync
+	go server.Write([]byte("\x00\x00\x00\x08d1:a"))
+	_, err := c.ReadMessage()
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Fatalf("Conn.ReadMessage() error = %v, want a timeout", err)
+	}
+	go server.Write([]byte("1:be\x00\x00\x00\x03i2e"))
+	if _, err2 := c.ReadMessage(); err2 != err {
+		t.Errorf("Conn.ReadMessage() error = %v, want %v", err2, err)
+	}
+}
+
+func TestConn_ReadMessage_oversized(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() {
+		server.Write([]byte("\x00\x00\x00\x0a" + strings.Repeat("x", 10)))
+		server.Write([]byte("\x00\x00\x00\x03i1e"))
+	}()
+
+	c := NewConn(client)
+	c.MaxFrameSize(8)
+	c.ReadTimeout(5 * time.Second)
+	_, err := c.ReadMessage()
+	if fe, ok := err.(FrameTooLargeError); !ok || fe.Size != 10 {
+		t.Fatalf("Conn.ReadMessage() error = %v, want FrameTooLargeError of 10 bytes", err)
+	}
+	// the oversized frame is discarded, the next message is read
+	if got, err := c.ReadMessage(); err != nil || !reflect.DeepEqual(got, bencode.BnCode{State: bencode.BnInt, Value: 1}) {
+		t.Errorf("Conn.ReadMessage() = %v, %v, want i1e", got, err)
+	}
+}
+
+func TestConn_ReadMessage_buffer(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	large := str(strings.Repeat("x", maxRetainedBuffer))
+	go func() {
+		w := NewConn(server)
+		w.WriteMessage(str("abc"))
+		w.WriteMessage(large)
+	}()
+
+	c := NewConn(client)
+	if _, err := c.ReadMessage(); err != nil {
+		t.Fatalf("Conn.ReadMessage() error = %v", err)
+	}
+	if cap(c.frame) == 0 {
+		t.Errorf("Conn.ReadMessage() did not keep the buffer of a small message")
+	}
+	got, err := c.ReadMessage()
+	if err != nil || !reflect.DeepEqual(got, large) {
+		t.Fatalf("Conn.ReadMessage() = %.20v, %v, want the large message", got, err)
+	}
+	if cap(c.frame) != 0 {
+		t.Errorf("Conn.ReadMessage() kept a buffer of %d bytes", cap(c.frame))
+	}
+}
@@ -0,0 +1,102 @@
+package bencode
+
+import (
+	"errors"
+)
+
+// Value is the set of Go types held by the Value of BnCode.
+type Value interface {
+	int | string | []BnCode | map[string]BnCode | OrderedDict
+}
+
+var (
+	errNotInt         = errors.New("Given Value is not an int")
+	errNotString      = errors.New("Given Value is not a string")
+	errNotList        = errors.New("Given Value is not a list")
+	errNotDict        = errors.New("Given Value is not a dictionary")
+	errNotOrderedDict = errors.New("Given Value is not an ordered dictionary")
+)
+
+// stateOf returns the State of the nodes holding T and the error returned when the node holds something else.
+func stateOf[T Value]() (State, error) {
+	var zero T
+	switch any(zero).(type) {
+	case int:
+		return BnInt, errNotInt
+	case string:
+		return BnString, errNotString
+	case []BnCode:
+		return BnList, errNotList
+	case map[string]BnCode:
+		return BnDict, errNotDict
+	default:
+		return BnDict, errNotOrderedDict
+	}
+}
+
+// As converts the Value of the node to T, checking that the State matches it.
+// OrderedDict values are converted to map[string]BnCode with OrderedDict.Map.
+//
+// Returns error if the node does not hold T.
+func As[T Value](node BnCode) (T, error) {
+	state, notValue := stateOf[T]()
+	if node.State != state {
+		var zero T
+		return zero, notValue
+	}
+	if d, ok := node.Value.(OrderedDict); ok {
+		if m, ok := any(d.Map()).(T); ok {
+			return m, nil
+		}
+	}
+	val, ok := node.Value.(T)
+	if !ok {
+		return val, notValue
+	}
+	return val, nil
+}
+
+// MustAs is like As but panics if the node does not hold T.
+func MustAs[T Value](node BnCode) T {
+	val, err := As[T](node)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// ListOf converts the list node to a slice of T.
+//
+// Returns error if the node is not a list or any of its elements does not hold T,
+// in which case the error is prefixed with the index of the element.
+func ListOf[T Value](node BnCode) ([]T, error) {
+	list, err := As[[]BnCode](node)
+	if err != nil {
+		return nil, err
+	}
+	rc := make([]T, len(list))
+	for i, elem := range list {
+		if rc[i], err = As[T](elem); err != nil {
+			return nil, wrapPath(Path{}.Index(i), err)
+		}
+	}
+	return rc, nil
+}
+
+// DictOf converts the dictionary node to a map of T.
+//
+// Returns error if the node is not a dictionary or any of its values does not hold T,
+// in which case the error is prefixed with the key of the value.
+func DictOf[T Value](node BnCode) (map[string]T, error) {
+	dict, err := As[map[string]BnCode](node)
+	if err != nil {
+		return nil, err
+	}
+	rc := make(map[string]T, len(dict))
+	for _, k := range sortedKeys(dict) {
+		if rc[k], err = As[T](dict[k]); err != nil {
+			return nil, wrapPath(Path{}.Key(k), err)
+		}
+	}
+	return rc, nil
+}
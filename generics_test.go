@@ -0,0 +1,82 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAs(t *testing.T) {
+	ordered := BnCode{State: BnDict, Value: OrderedDict{{Key: "a", Value: BnCode{State: BnInt, Value: 1}}}}
+
+	if got, err := As[int](BnCode{State: BnInt, Value: 5}); err != nil || got != 5 {
+		t.Errorf("As[int]() = %v, %v, want 5", got, err)
+	}
+	if got, err := As[string](BnCode{State: BnString, Value: "s"}); err != nil || got != "s" {
+		t.Errorf("As[string]() = %v, %v, want s", got, err)
+	}
+	if got, err := As[map[string]BnCode](ordered); err != nil || !reflect.DeepEqual(got, map[string]BnCode{"a": {State: BnInt, Value: 1}}) {
+		t.Errorf("As[map[string]BnCode]() = %v, %v", got, err)
+	}
+	if _, err := As[OrderedDict](ordered); err != nil {
+		t.Errorf("As[OrderedDict]() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{name: "Wrong state", fn: func() error { _, err := As[int](BnCode{State: BnString, Value: 5}); return err }},
+		{name: "Wrong value", fn: func() error { _, err := As[string](BnCode{State: BnString, Value: 5}); return err }},
+		{name: "List as dictionary", fn: func() error { _, err := As[map[string]BnCode](BnCode{State: BnList, Value: []BnCode{}}); return err }},
+		{name: "Map as ordered", fn: func() error { _, err := As[OrderedDict](BnCode{State: BnDict, Value: map[string]BnCode{}}); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); err == nil {
+				t.Errorf("As() accepted a mismatched node")
+			}
+		})
+	}
+}
+
+func TestMustAs(t *testing.T) {
+	if got := MustAs[int](BnCode{State: BnInt, Value: 1}); got != 1 {
+		t.Errorf("MustAs[int]() = %v, want 1", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustAs[int]() did not panic")
+		}
+	}()
+	MustAs[int](BnCode{State: BnString, Value: "1"})
+}
+
+func TestListOf(t *testing.T) {
+	got, err := ListOf[int](BnCode{State: BnList, Value: []BnCode{{State: BnInt, Value: 1}, {State: BnInt, Value: 2}}})
+	if err != nil || !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("ListOf[int]() = %v, %v, want [1 2]", got, err)
+	}
+
+	_, err = ListOf[int](BnCode{State: BnList, Value: []BnCode{{State: BnInt, Value: 1}, {State: BnString, Value: "2"}}})
+	if err == nil || err.Error() != "[1]: Given Value is not an int" {
+		t.Errorf("ListOf[int]() error = %v", err)
+	}
+	if _, err = ListOf[int](BnCode{State: BnInt, Value: 1}); err == nil {
+		t.Errorf("ListOf[int]() accepted an int")
+	}
+}
+
+func TestDictOf(t *testing.T) {
+	got, err := DictOf[string](BnCode{State: BnDict, Value: map[string]BnCode{"a": {State: BnString, Value: "x"}}})
+	if err != nil || !reflect.DeepEqual(got, map[string]string{"a": "x"}) {
+		t.Errorf("DictOf[string]() = %v, %v, want map[a:x]", got, err)
+	}
+
+	_, err = DictOf[string](BnCode{State: BnDict, Value: map[string]BnCode{"a": {State: BnString, Value: "x"}, "b": {State: BnInt, Value: 1}}})
+	if err == nil || err.Error() != "b: Given Value is not a string" {
+		t.Errorf("DictOf[string]() error = %v", err)
+	}
+	if _, err = DictOf[string](BnCode{State: BnList, Value: []BnCode{}}); err == nil {
+		t.Errorf("DictOf[string]() accepted a list")
+	}
+}
@@ -1,4 +1,4 @@
 module bencode
 
-go 1.14
+go 1.18
 
@@ -0,0 +1,175 @@
+package bencode
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InferSchema derives the narrowest Schema that all the samples satisfy.
+//
+// Ints get the range of the seen values, strings and lists the range of the seen lengths, list elements share
+// a single schema inferred from all of them. Dictionary keys seen in every sample are required, the others optional,
+// unseen keys are not allowed. Nodes of different types at the same place produce OneOf alternatives.
+func InferSchema(samples ...BnCode) Schema {
+	byState := make(map[State][]BnCode)
+	for _, s := range samples {
+		byState[s.State] = append(byState[s.State], s)
+	}
+	states := make([]State, 0, len(byState))
+	for state := range byState {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+
+	switch len(states) {
+	case 0:
+		return Schema{}
+	case 1:
+		return inferState(states[0], byState[states[0]])
+	}
+	var rc Schema
+	for _, state := range states {
+		rc.OneOf = append(rc.OneOf, inferState(state, byState[state]))
+	}
+	return rc
+}
+
+// extendRange extends the range to contain n, the first value initializes it.
+func extendRange(r *Range, n int, first bool) {
+	if first || n < r.Min {
+		r.Min = n
+	}
+	if first || n > r.Max {
+		r.Max = n
+	}
+}
+
+// inferState infers the schema of nodes sharing the same State.
+func inferState(state State, nodes []BnCode) Schema {
+	rc := Schema{Types: []State{state}}
+	switch state {
+	case BnInt:
+		rc.Range = &Range{}
+		for i, n := range nodes {
+			v, _ := n.GetInt()
+			extendRange(rc.Range, v, i == 0)
+		}
+	case BnString:
+		rc.Len = &Range{}
+		for i, n := range nodes {
+			v, _ := n.GetString()
+			extendRange(rc.Len, len(v), i == 0)
+		}
+	case BnList:
+		rc.Len = &Range{}
+		var elems []BnCode
+		for i, n := range nodes {
+			v, _ := n.GetList()
+			extendRange(rc.Len, len(v), i == 0)
+			elems = append(elems, v...)
+		}
+		if len(elems) > 0 {
+			elem := InferSchema(elems...)
+			rc.Elem = &elem
+		}
+	case BnDict:
+		values := make(map[string][]BnCode)
+		for _, n := range nodes {
+			v, _ := n.GetDict()
+			for k, val := range v {
+				values[k] = append(values[k], val)
+			}
+		}
+		rc.Keys = make(map[string]Schema, len(values))
+		for k, vals := range values {
+			rc.Keys[k] = InferSchema(vals...)
+			if len(vals) == len(nodes) {
+				rc.Required = append(rc.Required, k)
+			}
+		}
+		sort.Strings(rc.Required)
+	}
+	return rc
+}
+
+// String formats the schema in a readable, indented form, for example
+//
+//	dict {
+//	  "length": int [0, 1024]
+//	  "name"?: string len [1, 64] pattern ^\w+$
+//	  "path": list len [1, 3] of string len [1, 10]
+//	  ...
+//	}
+//
+// Optional keys are marked with a question mark and the dots tell that extra keys are allowed.
+func (s Schema) String() string {
+	var sb strings.Builder
+	s.write(&sb, "")
+	return sb.String()
+}
+
+func (s *Schema) write(sb *strings.Builder, indent string) {
+	if len(s.OneOf) > 0 {
+		sb.WriteString("one of (")
+		for i := range s.OneOf {
+			if i > 0 {
+				sb.WriteString(" | ")
+			}
+			s.OneOf[i].write(sb, indent)
+		}
+		sb.WriteString(")")
+		return
+	}
+
+	switch {
+	case len(s.Types) == 1 && s.Types[0] == BnDict:
+		sb.WriteString("dict {\n")
+		required := make(map[string]bool, len(s.Required))
+		keys := make([]string, 0, len(s.Keys))
+		for _, k := range s.Required {
+			required[k] = true
+			if _, ok := s.Keys[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		for k := range s.Keys {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sb.WriteString(indent + "  " + strconv.Quote(k))
+			if !required[k] {
+				sb.WriteString("?")
+			}
+			sb.WriteString(": ")
+			if keySchema, ok := s.Keys[k]; ok {
+				keySchema.write(sb, indent+"  ")
+			} else {
+				sb.WriteString("any")
+			}
+			sb.WriteString("\n")
+		}
+		if s.AllowExtra {
+			sb.WriteString(indent + "  ...\n")
+		}
+		sb.WriteString(indent + "}")
+		return
+	default:
+		sb.WriteString(typeNames(s.Types))
+	}
+
+	if s.Range != nil && s.accepts(BnInt) {
+		sb.WriteString(" " + s.Range.String())
+	}
+	if s.Len != nil && (s.accepts(BnString) || s.accepts(BnList)) {
+		sb.WriteString(" len " + s.Len.String())
+	}
+	if s.Pattern != nil && s.accepts(BnString) {
+		sb.WriteString(" pattern " + s.Pattern.String())
+	}
+	if s.Elem != nil && s.accepts(BnList) {
+		sb.WriteString(" of ")
+		s.Elem.write(sb, indent)
+	}
+}
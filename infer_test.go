@@ -0,0 +1,75 @@
+package bencode
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	samples := []BnCode{
+		decodeString(t, "d6:lengthi10e4:name3:abc4:pathl1:ae7:privatei1ee"),
+		decodeString(t, "d6:lengthi-5e4:name1:x4:pathl2:bb3:ccce4:tagsi1ee"),
+		decodeString(t, "d6:lengthi7e4:name5:hello4:pathle4:tags3:onee"),
+	}
+	want := Schema{
+		Types: []State{BnDict},
+		Keys: map[string]Schema{
+			"length":  {Types: []State{BnInt}, Range: &Range{Min: -5, Max: 10}},
+			"name":    {Types: []State{BnString}, Len: &Range{Min: 1, Max: 5}},
+			"path":    {Types: []State{BnList}, Len: &Range{Min: 0, Max: 2}, Elem: &Schema{Types: []State{BnString}, Len: &Range{Min: 1, Max: 3}}},
+			"private": {Types: []State{BnInt}, Range: &Range{Min: 1, Max: 1}},
+			"tags": {OneOf: []Schema{
+				{Types: []State{BnInt}, Range: &Range{Min: 1, Max: 1}},
+				{Types: []State{BnString}, Len: &Range{Min: 3, Max: 3}},
+			}},
+		},
+		Required: []string{"length", "name", "path"},
+	}
+
+	got := InferSchema(samples...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InferSchema() = %v, want %v", got, want)
+	}
+	for i, s := range samples {
+		if errs := Validate(s, got); errs != nil {
+			t.Errorf("Validate() of sample %d = %v", i, errs)
+		}
+	}
+	if errs := Validate(decodeString(t, "d6:lengthi11e4:name1:x4:pathlee"), got); len(errs) != 1 {
+		t.Errorf("Validate() = %v, want the length out of range", errs)
+	}
+
+	if got := InferSchema(); !reflect.DeepEqual(got, Schema{}) {
+		t.Errorf("InferSchema() of no samples = %v, want any", got)
+	}
+}
+
+func TestSchema_String(t *testing.T) {
+	schema := Schema{
+		Types: []State{BnDict},
+		Keys: map[string]Schema{
+			"info": {Types: []State{BnDict}, Keys: map[string]Schema{
+				"name": {Types: []State{BnString}, Len: &Range{Min: 1, Max: 64}, Pattern: regexp.MustCompile(`^\w+$`)},
+			}, AllowExtra: true},
+			"n":     {Types: []State{BnInt}, Range: &Range{Min: 0, Max: math.MaxInt}},
+			"path":  {Types: []State{BnList}, Len: &Range{Min: 1, Max: 3}, Elem: &Schema{Types: []State{BnString}}},
+			"value": {OneOf: []Schema{{Types: []State{BnInt}}, {Types: []State{BnList}, Elem: &Schema{}}}},
+		},
+		Required: []string{"info", "missing"},
+	}
+	want := `dict {
+  "info": dict {
+    "name"?: string len [1, 64] pattern ^\w+$
+    ...
+  }
+  "missing": any
+  "n"?: int [0, inf)
+  "path"?: list len [1, 3] of string
+  "value"?: one of (int | list of any)
+}`
+	if got := schema.String(); got != want {
+		t.Errorf("Schema.String() = \n%s\nwant\n%s", got, want)
+	}
+}
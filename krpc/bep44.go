@@ -0,0 +1,137 @@
+package krpc
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha1"
+	"fmt"
+	"strconv"
+
+	"bencode"
+)
+
+const (
+	// MaxValueSize is the largest encoded "v" of a stored item (BEP 44)
+	MaxValueSize = 1000
+	// MaxSaltSize is the longest salt of a mutable item (BEP 44)
+	MaxSaltSize = 64
+)
+
+const (
+	// ErrorValueTooBig is sent back when "v" of the put exceeds MaxValueSize
+	ErrorValueTooBig = 205
+	// ErrorInvalidSignature is sent back when the signature of a mutable put does not verify
+	ErrorInvalidSignature = 206
+	// ErrorSaltTooBig is sent back when the salt of a mutable put exceeds MaxSaltSize
+	ErrorSaltTooBig = 207
+	// ErrorCasMismatch is sent back when "cas" of the put does not match the stored sequence number
+	ErrorCasMismatch = 301
+	// ErrorSeqTooLow is sent back when the sequence number of the put is less than the stored one
+	ErrorSeqTooLow = 302
+)
+
+// Item is a value stored in the DHT with get and put queries (BEP 44).
+//
+// Items without K are immutable and addressed by the hash of the value,
+// items with K are mutable and addressed by the hash of the key and salt.
+type Item struct {
+	V    bencode.BnCode
+	K    ed25519.PublicKey
+	Salt string
+	Seq  int
+	Sig  []byte
+}
+
+// Mutable tells whether the item is signed by a key.
+func (i *Item) Mutable() bool {
+	return i.K != nil
+}
+
+func encodeValue(v bencode.BnCode) ([]byte, error) {
+	rc, err := bencode.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(rc) > MaxValueSize {
+		return nil, fmt.Errorf("Encoded value is %d bytes long, at most %d allowed", len(rc), MaxValueSize)
+	}
+	return rc, nil
+}
+
+// SignatureBuffer builds the exact byte string that is signed for a mutable item,
+// the bencoded "salt", "seq" and "v" entries without the enclosing dictionary.
+//
+// Returns error if the salt exceeds MaxSaltSize or the encoded value exceeds MaxValueSize.
+func SignatureBuffer(salt string, seq int, v bencode.BnCode) ([]byte, error) {
+	if len(salt) > MaxSaltSize {
+		return nil, fmt.Errorf("Salt is %d bytes long, at most %d allowed", len(salt), MaxSaltSize)
+	}
+	value, err := encodeValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var rc []byte
+	if salt != "" {
+		rc = append(rc, "4:salt"...)
+		rc = append(rc, strconv.Itoa(len(salt))...)
+		rc = append(rc, ':')
+		rc = append(rc, salt...)
+	}
+	rc = append(rc, "3:seqi"...)
+	rc = append(rc, strconv.Itoa(seq)...)
+	rc = append(rc, "e1:v"...)
+	rc = append(rc, value...)
+	return rc, nil
+}
+
+// Sign turns the item into a mutable one, owned by the given key.
+//
+// Returns error if the item violates the size limits.
+func (i *Item) Sign(key ed25519.PrivateKey) error {
+	buf, err := SignatureBuffer(i.Salt, i.Seq, i.V)
+	if err != nil {
+		return err
+	}
+	i.K = key.Public().(ed25519.PublicKey)
+	i.Sig = ed25519.Sign(key, buf)
+	return nil
+}
+
+// Verify checks the size limits of the item and, for mutable items, the signature.
+func (i *Item) Verify() error {
+	if !i.Mutable() {
+		_, err := encodeValue(i.V)
+		return err
+	}
+	if len(i.K) != ed25519.PublicKeySize {
+		return fmt.Errorf("Public key must be %d bytes long, got %d", ed25519.PublicKeySize, len(i.K))
+	}
+	if len(i.Sig) != ed25519.SignatureSize {
+		return fmt.Errorf("Signature must be %d bytes long, got %d", ed25519.SignatureSize, len(i.Sig))
+	}
+	buf, err := SignatureBuffer(i.Salt, i.Seq, i.V)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(i.K, buf, i.Sig) {
+		return fmt.Errorf("Invalid signature")
+	}
+	return nil
+}
+
+// Target returns the DHT key of the item: SHA-1 of the key and salt for mutable items,
+// SHA-1 of the encoded value for immutable ones.
+func (i *Item) Target() (NodeID, error) {
+	if i.Mutable() {
+		var buf bytes.Buffer
+		buf.Write(i.K)
+		buf.WriteString(i.Salt)
+		return sha1.Sum(buf.Bytes()), nil
+	}
+	value, err := encodeValue(i.V)
+	if err != nil {
+		return NodeID{}, err
+	}
+	return sha1.Sum(value), nil
+}
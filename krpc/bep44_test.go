@@ -0,0 +1,224 @@
+package krpc
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bencode"
+)
+
+// test vectors from http://bittorrent.org/beps/bep_0044.html
+const (
+	vectorPublicKey = "77ff84905a91936367c01360803104f92432fcd904a43511876df5cdf3e7e548"
+	vectorSig       = "305ac8aeb6c9c151fa120f120ea2cfb923564e11552d06a5d856091e5e853cff1260d3f39e4999684aa92eb73ffd136e6f4f3ecbfda0ce53a1608ecd7ae21f01"
+	vectorSaltSig   = "6834284b6b24c3204eb2fea824d82f88883a3d95e8b4a21b8c0ded553d17d17ddf9a8a7104b1258f30bed3787e6cb896fca78c58f8e03b5f18f14951a87d9a08"
+)
+
+func mustHex(s string) []byte {
+	rc, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return rc
+}
+
+func helloWorld() bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnString, Value: "Hello World!"}
+}
+
+func TestSignatureBuffer(t *testing.T) {
+	type args struct {
+		salt string
+		seq  int
+		v    bencode.BnCode
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Without salt",
+			args:    args{salt: "", seq: 1, v: helloWorld()},
+			want:    "3:seqi1e1:v12:Hello World!",
+			wantErr: false,
+		},
+		{
+			name:    "With salt",
+			args:    args{salt: "foobar", seq: 1, v: helloWorld()},
+			want:    "4:salt6:foobar3:seqi1e1:v12:Hello World!",
+			wantErr: false,
+		},
+		{
+			name:    "Value too big",
+			args:    args{seq: 1, v: bencode.BnCode{State: bencode.BnString, Value: strings.Repeat("x", MaxValueSize)}},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Salt too big",
+			args:    args{salt: strings.Repeat("s", MaxSaltSize+1), seq: 1, v: helloWorld()},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SignatureBuffer(tt.args.salt, tt.args.seq, tt.args.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignatureBuffer() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("SignatureBuffer() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestItem_Verify(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    Item
+		wantErr bool
+	}{
+		{
+			name:    "Mutable item",
+			item:    Item{V: helloWorld(), K: mustHex(vectorPublicKey), Seq: 1, Sig: mustHex(vectorSig)},
+			wantErr: false,
+		},
+		{
+			name:    "Mutable item with salt",
+			item:    Item{V: helloWorld(), K: mustHex(vectorPublicKey), Salt: "foobar", Seq: 1, Sig: mustHex(vectorSaltSig)},
+			wantErr: false,
+		},
+		{
+			name:    "Wrong sequence number",
+			item:    Item{V: helloWorld(), K: mustHex(vectorPublicKey), Seq: 2, Sig: mustHex(vectorSig)},
+			wantErr: true,
+		},
+		{
+			name:    "Short signature",
+			item:    Item{V: helloWorld(), K: mustHex(vectorPublicKey), Seq: 1, Sig: mustHex(vectorSig)[:32]},
+			wantErr: true,
+		},
+		{
+			name:    "Immutable item",
+			item:    Item{V: helloWorld()},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.item.Verify(); (err != nil) != tt.wantErr {
+				t.Errorf("Item.Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestItem_Target(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		want string
+	}{
+		{
+			name: "Mutable item",
+			item: Item{V: helloWorld(), K: mustHex(vectorPublicKey), Seq: 1},
+			want: "4a533d47ec9c7d95b1ad75f576cffc641853b750",
+		},
+		{
+			name: "Mutable item with salt",
+			item: Item{V: helloWorld(), K: mustHex(vectorPublicKey), Salt: "foobar", Seq: 1},
+			want: "411eba73b6f087ca51a3795d9c8c938d365e32c1",
+		},
+		{
+			name: "Immutable item",
+			item: Item{V: helloWorld()},
+			want: "e5f96f6f38320f0f33959cb4d3d656452117aadb",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.item.Target()
+			if err != nil {
+				t.Fatalf("Item.Target() error = %v", err)
+			}
+			if hex.EncodeToString(got[:]) != tt.want {
+				t.Errorf("Item.Target() = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestItem_Sign(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := Item{V: helloWorld(), Salt: "foobar", Seq: 4}
+	if err = item.Sign(key); err != nil {
+		t.Fatalf("Item.Sign() error = %v", err)
+	}
+	if err = item.Verify(); err != nil {
+		t.Errorf("Item.Verify() error = %v", err)
+	}
+
+	item.Seq++
+	if err = item.Verify(); err == nil {
+		t.Errorf("Item.Verify() succeeded after changing the sequence number")
+	}
+}
+
+func TestPutRoundTrip(t *testing.T) {
+	cas := 0
+	query := &Query{TransactionID: "aa", Method: MethodPut, Args: Args{
+		ID:    mustID("abcdefghij0123456789"),
+		Token: "aoeusnth",
+		Cas:   &cas,
+		Item:  &Item{V: helloWorld(), K: mustHex(vectorPublicKey), Salt: "foobar", Seq: 1, Sig: mustHex(vectorSaltSig)},
+	}}
+	data, err := Marshal(query)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, query) {
+		t.Errorf("Unmarshal() = %#v, want %#v", got, query)
+	}
+	if err = got.(*Query).Args.Item.Verify(); err != nil {
+		t.Errorf("Item.Verify() error = %v", err)
+	}
+}
+
+func TestGetResponse(t *testing.T) {
+	data := "d1:rd2:id20:mnopqrstuvwxyz1234561:k32:" + string(mustHex(vectorPublicKey)) +
+		"3:seqi1e3:sig64:" + string(mustHex(vectorSig)) + "5:token8:aoeusnth1:v12:Hello World!e1:t2:aa1:y1:re"
+	msg, err := Unmarshal([]byte(data))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	item := msg.(*Response).Return.Item
+	if item == nil {
+		t.Fatalf("Unmarshal() did not return the item")
+	}
+	if err = item.Verify(); err != nil {
+		t.Errorf("Item.Verify() error = %v", err)
+	}
+
+	enc, err := Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(enc) != data {
+		t.Errorf("Marshal() = %q, want %q", enc, data)
+	}
+}
@@ -0,0 +1,647 @@
+// Package krpc implements the KRPC message format used by the BitTorrent DHT (BEP 5).
+//
+// Messages are marshalled through the bencode package, see http://bittorrent.org/beps/bep_0005.html
+package krpc
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+
+	"bencode"
+)
+
+const (
+	// MaxMessageSize is the largest encoded message accepted or produced by this package
+	MaxMessageSize = 1500
+	// MaxTransactionIDLength is the longest transaction id accepted by this package
+	MaxTransactionIDLength = 16
+	// MaxTokenLength is the longest announce token accepted by this package
+	MaxTokenLength = 64
+)
+
+const (
+	// TypeQuery is the value of the "y" key for queries
+	TypeQuery = "q"
+	// TypeResponse is the value of the "y" key for responses
+	TypeResponse = "r"
+	// TypeError is the value of the "y" key for errors
+	TypeError = "e"
+)
+
+const (
+	// MethodPing is the most basic query, used to check if the node is alive
+	MethodPing = "ping"
+	// MethodFindNode is used to find the contact information for a node given its id
+	MethodFindNode = "find_node"
+	// MethodGetPeers is used to get peers associated with a torrent info hash
+	MethodGetPeers = "get_peers"
+	// MethodAnnouncePeer announces that the querying peer is downloading a torrent
+	MethodAnnouncePeer = "announce_peer"
+	// MethodGet retrieves an item stored in the DHT (BEP 44)
+	MethodGet = "get"
+	// MethodPut stores an item in the DHT (BEP 44)
+	MethodPut = "put"
+)
+
+const (
+	// ErrorGeneric is the code of a generic error
+	ErrorGeneric = 201
+	// ErrorServer is the code of a server error
+	ErrorServer = 202
+	// ErrorProtocol is the code of a protocol error, such as a malformed packet or bogus token
+	ErrorProtocol = 203
+	// ErrorMethodUnknown is the code sent back for unsupported queries
+	ErrorMethodUnknown = 204
+)
+
+// Message is implemented by Query, Response and Error.
+type Message interface {
+	// Transaction returns the transaction id that correlates queries with responses
+	Transaction() string
+
+	toBnCode() (bencode.BnCode, error)
+}
+
+// Args holds the arguments of a query. Only the fields relevant for the Method are encoded.
+type Args struct {
+	// ID is the id of the querying node, required for all queries
+	ID NodeID
+	// Target is the id of the node looked up by find_node
+	Target NodeID
+	// InfoHash is the torrent looked up by get_peers and announced by announce_peer
+	InfoHash NodeID
+	// Port is the port announced by announce_peer
+	Port int
+	// ImpliedPort tells the receiver of announce_peer to use the source port of the packet instead of Port
+	ImpliedPort bool
+	// Token is the value received in the get_peers or get response, required by announce_peer and put
+	Token string
+	// Seq asks get to return the mutable item only if its sequence number is greater
+	Seq *int
+	// Cas makes put fail unless the stored sequence number matches
+	Cas *int
+	// Item is the value stored by put
+	Item *Item
+}
+
+// Query is a KRPC message of type "q".
+type Query struct {
+	TransactionID string
+	// Version is the optional client version string, "v" key
+	Version string
+	Method  string
+	Args    Args
+}
+
+// Return holds the values of a response. Empty fields are omitted from the encoded message.
+type Return struct {
+	// ID is the id of the responding node
+	ID NodeID
+	// Nodes are the closest IPv4 contacts, encoded as compact node info
+	Nodes []NodeInfo
+	// Nodes6 are the closest IPv6 contacts, encoded as compact node info
+	Nodes6 []NodeInfo
+	// Token has to be presented by the querying node in a subsequent announce_peer
+	Token string
+	// Values are the peers of the torrent requested with get_peers
+	Values []Peer
+	// Item is the value found by get, the salt is never sent back
+	Item *Item
+}
+
+// Response is a KRPC message of type "r".
+type Response struct {
+	TransactionID string
+	// Version is the optional client version string, "v" key
+	Version string
+	Return  Return
+}
+
+// Error is a KRPC message of type "e". It implements the error interface,
+// so it can be returned from query handlers as is.
+type Error struct {
+	TransactionID string
+	// Version is the optional client version string, "v" key
+	Version string
+	Code    int
+	Message string
+}
+
+// Transaction returns the transaction id of the query.
+func (q *Query) Transaction() string { return q.TransactionID }
+
+// Transaction returns the transaction id of the response.
+func (r *Response) Transaction() string { return r.TransactionID }
+
+// Transaction returns the transaction id of the error.
+func (e *Error) Transaction() string { return e.TransactionID }
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("KRPC error %d: %s", e.Code, e.Message)
+}
+
+func str(s string) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnString, Value: s}
+}
+
+func integer(i int) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnInt, Value: i}
+}
+
+func envelope(t, y, v string) map[string]bencode.BnCode {
+	rc := map[string]bencode.BnCode{"t": str(t), "y": str(y)}
+	if v != "" {
+		rc["v"] = str(v)
+	}
+	return rc
+}
+
+func checkTransactionID(t string) error {
+	if t == "" {
+		return fmt.Errorf("Transaction id is empty")
+	}
+	if len(t) > MaxTransactionIDLength {
+		return fmt.Errorf("Transaction id is %d bytes long, at most %d allowed", len(t), MaxTransactionIDLength)
+	}
+	return nil
+}
+
+func (q *Query) toBnCode() (bencode.BnCode, error) {
+	if err := checkTransactionID(q.TransactionID); err != nil {
+		return bencode.BnCode{}, err
+	}
+	if q.Method == "" {
+		return bencode.BnCode{}, fmt.Errorf("Query method is empty")
+	}
+
+	args := map[string]bencode.BnCode{"id": str(q.Args.ID.String())}
+	switch q.Method {
+	case MethodFindNode:
+		args["target"] = str(q.Args.Target.String())
+	case MethodGetPeers:
+		args["info_hash"] = str(q.Args.InfoHash.String())
+	case MethodGet:
+		args["target"] = str(q.Args.Target.String())
+		if q.Args.Seq != nil {
+			args["seq"] = integer(*q.Args.Seq)
+		}
+	case MethodPut:
+		if err := checkToken(q.Method, q.Args.Token); err != nil {
+			return bencode.BnCode{}, err
+		}
+		if q.Args.Item == nil {
+			return bencode.BnCode{}, fmt.Errorf("put requires an item")
+		}
+		if err := checkItem(q.Args.Item); err != nil {
+			return bencode.BnCode{}, err
+		}
+		args["token"] = str(q.Args.Token)
+		args["v"] = q.Args.Item.V
+		if q.Args.Item.Mutable() {
+			putMutable(args, q.Args.Item)
+			if q.Args.Item.Salt != "" {
+				args["salt"] = str(q.Args.Item.Salt)
+			}
+			if q.Args.Cas != nil {
+				args["cas"] = integer(*q.Args.Cas)
+			}
+		}
+	case MethodAnnouncePeer:
+		if err := checkToken(q.Method, q.Args.Token); err != nil {
+			return bencode.BnCode{}, err
+		}
+		if q.Args.Port < 0 || q.Args.Port > 0xffff {
+			return bencode.BnCode{}, fmt.Errorf("Port %d is out of range", q.Args.Port)
+		}
+		args["info_hash"] = str(q.Args.InfoHash.String())
+		args["port"] = integer(q.Args.Port)
+		args["token"] = str(q.Args.Token)
+		if q.Args.ImpliedPort {
+			args["implied_port"] = integer(1)
+		}
+	}
+
+	rc := envelope(q.TransactionID, TypeQuery, q.Version)
+	rc["q"] = str(q.Method)
+	rc["a"] = bencode.BnCode{State: bencode.BnDict, Value: args}
+	return bencode.BnCode{State: bencode.BnDict, Value: rc}, nil
+}
+
+func checkToken(method, token string) error {
+	if token == "" {
+		return fmt.Errorf("%s requires a token", method)
+	}
+	if len(token) > MaxTokenLength {
+		return fmt.Errorf("Token is %d bytes long, at most %d allowed", len(token), MaxTokenLength)
+	}
+	return nil
+}
+
+func checkItem(i *Item) error {
+	if _, err := encodeValue(i.V); err != nil {
+		return err
+	}
+	if len(i.Salt) > MaxSaltSize {
+		return fmt.Errorf("Salt is %d bytes long, at most %d allowed", len(i.Salt), MaxSaltSize)
+	}
+	if i.Mutable() {
+		if len(i.K) != ed25519.PublicKeySize {
+			return fmt.Errorf("Public key must be %d bytes long, got %d", ed25519.PublicKeySize, len(i.K))
+		}
+		if len(i.Sig) != ed25519.SignatureSize {
+			return fmt.Errorf("Signature must be %d bytes long, got %d", ed25519.SignatureSize, len(i.Sig))
+		}
+	}
+	return nil
+}
+
+func putMutable(dict map[string]bencode.BnCode, i *Item) {
+	dict["k"] = str(string(i.K))
+	dict["sig"] = str(string(i.Sig))
+	dict["seq"] = integer(i.Seq)
+}
+
+func (r *Response) toBnCode() (bencode.BnCode, error) {
+	if err := checkTransactionID(r.TransactionID); err != nil {
+		return bencode.BnCode{}, err
+	}
+
+	ret := map[string]bencode.BnCode{"id": str(r.Return.ID.String())}
+	if len(r.Return.Nodes) > 0 {
+		nodes, err := EncodeNodes(r.Return.Nodes)
+		if err != nil {
+			return bencode.BnCode{}, err
+		}
+		ret["nodes"] = str(nodes)
+	}
+	if len(r.Return.Nodes6) > 0 {
+		nodes, err := EncodeNodes6(r.Return.Nodes6)
+		if err != nil {
+			return bencode.BnCode{}, err
+		}
+		ret["nodes6"] = str(nodes)
+	}
+	if r.Return.Token != "" {
+		if len(r.Return.Token) > MaxTokenLength {
+			return bencode.BnCode{}, fmt.Errorf("Token is %d bytes long, at most %d allowed", len(r.Return.Token), MaxTokenLength)
+		}
+		ret["token"] = str(r.Return.Token)
+	}
+	if r.Return.Item != nil {
+		if err := checkItem(r.Return.Item); err != nil {
+			return bencode.BnCode{}, err
+		}
+		ret["v"] = r.Return.Item.V
+		if r.Return.Item.Mutable() {
+			putMutable(ret, r.Return.Item)
+		}
+	}
+	if len(r.Return.Values) > 0 {
+		values := make([]bencode.BnCode, len(r.Return.Values))
+		for i, p := range r.Return.Values {
+			peer, err := EncodePeer(p)
+			if err != nil {
+				return bencode.BnCode{}, err
+			}
+			values[i] = str(peer)
+		}
+		ret["values"] = bencode.BnCode{State: bencode.BnList, Value: values}
+	}
+
+	rc := envelope(r.TransactionID, TypeResponse, r.Version)
+	rc["r"] = bencode.BnCode{State: bencode.BnDict, Value: ret}
+	return bencode.BnCode{State: bencode.BnDict, Value: rc}, nil
+}
+
+func (e *Error) toBnCode() (bencode.BnCode, error) {
+	if err := checkTransactionID(e.TransactionID); err != nil {
+		return bencode.BnCode{}, err
+	}
+
+	rc := envelope(e.TransactionID, TypeError, e.Version)
+	rc["e"] = bencode.BnCode{State: bencode.BnList, Value: []bencode.BnCode{integer(e.Code), str(e.Message)}}
+	return bencode.BnCode{State: bencode.BnDict, Value: rc}, nil
+}
+
+// Marshal encodes the message into its wire format.
+//
+// Returns error if the message is missing required fields or the encoded message exceeds MaxMessageSize.
+func Marshal(m Message) ([]byte, error) {
+	node, err := m.toBnCode()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := bencode.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+	if len(rc) > MaxMessageSize {
+		return nil, fmt.Errorf("Encoded message is %d bytes long, at most %d allowed", len(rc), MaxMessageSize)
+	}
+	return rc, nil
+}
+
+// Unmarshal parses a single KRPC message. The returned Message is one of *Query, *Response or *Error.
+//
+// Queries with unknown methods are returned with only the id argument set,
+// so that the caller could reply with ErrorMethodUnknown.
+func Unmarshal(data []byte) (Message, error) {
+	if len(data) > MaxMessageSize {
+		return nil, fmt.Errorf("Message is %d bytes long, at most %d allowed", len(data), MaxMessageSize)
+	}
+
+	reader := bytes.NewReader(data)
+	node, err := bencode.Decode(reader)
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("Unexpected %d trailing bytes after the message", reader.Len())
+	}
+	dict, err := node.GetDict()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := requireString(dict, "t")
+	if err != nil {
+		return nil, err
+	}
+	if err = checkTransactionID(t); err != nil {
+		return nil, err
+	}
+	y, err := requireString(dict, "y")
+	if err != nil {
+		return nil, err
+	}
+	v, _, err := optionalString(dict, "v")
+	if err != nil {
+		return nil, err
+	}
+
+	switch y {
+	case TypeQuery:
+		q := &Query{TransactionID: t, Version: v}
+		if err = q.parse(dict); err != nil {
+			return nil, err
+		}
+		return q, nil
+	case TypeResponse:
+		r := &Response{TransactionID: t, Version: v}
+		if err = r.parse(dict); err != nil {
+			return nil, err
+		}
+		return r, nil
+	case TypeError:
+		e := &Error{TransactionID: t, Version: v}
+		if err = e.parse(dict); err != nil {
+			return nil, err
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("Unknown message type %q", y)
+	}
+}
+
+func (q *Query) parse(dict map[string]bencode.BnCode) error {
+	var err error
+	if q.Method, err = requireString(dict, "q"); err != nil {
+		return err
+	}
+	args, err := requireDict(dict, "a")
+	if err != nil {
+		return err
+	}
+	if q.Args.ID, err = requireNodeID(args, "id"); err != nil {
+		return err
+	}
+
+	switch q.Method {
+	case MethodFindNode:
+		q.Args.Target, err = requireNodeID(args, "target")
+	case MethodGetPeers:
+		q.Args.InfoHash, err = requireNodeID(args, "info_hash")
+	case MethodGet:
+		if q.Args.Target, err = requireNodeID(args, "target"); err != nil {
+			return err
+		}
+		q.Args.Seq, err = optionalIntPtr(args, "seq")
+	case MethodPut:
+		if q.Args.Token, err = requireString(args, "token"); err != nil {
+			return err
+		}
+		if len(q.Args.Token) > MaxTokenLength {
+			return fmt.Errorf("Token is %d bytes long, at most %d allowed", len(q.Args.Token), MaxTokenLength)
+		}
+		if q.Args.Item, err = parseItem(args); err != nil {
+			return err
+		}
+		if q.Args.Item == nil {
+			return fmt.Errorf("Required key %q is missing", "v")
+		}
+		if q.Args.Item.Salt, _, err = optionalString(args, "salt"); err != nil {
+			return err
+		}
+		if err = checkItem(q.Args.Item); err != nil {
+			return err
+		}
+		q.Args.Cas, err = optionalIntPtr(args, "cas")
+	case MethodAnnouncePeer:
+		if q.Args.InfoHash, err = requireNodeID(args, "info_hash"); err != nil {
+			return err
+		}
+		if q.Args.Port, err = requireInt(args, "port"); err != nil {
+			return err
+		}
+		if q.Args.Port < 0 || q.Args.Port > 0xffff {
+			return fmt.Errorf("Port %d is out of range", q.Args.Port)
+		}
+		if q.Args.Token, err = requireString(args, "token"); err != nil {
+			return err
+		}
+		if len(q.Args.Token) > MaxTokenLength {
+			return fmt.Errorf("Token is %d bytes long, at most %d allowed", len(q.Args.Token), MaxTokenLength)
+		}
+		implied, _, err := optionalInt(args, "implied_port")
+		if err != nil {
+			return err
+		}
+		q.Args.ImpliedPort = implied != 0
+	}
+	return err
+}
+
+func (r *Response) parse(dict map[string]bencode.BnCode) error {
+	ret, err := requireDict(dict, "r")
+	if err != nil {
+		return err
+	}
+	if r.Return.ID, err = requireNodeID(ret, "id"); err != nil {
+		return err
+	}
+
+	if nodes, ok, err := optionalString(ret, "nodes"); err != nil {
+		return err
+	} else if ok {
+		if r.Return.Nodes, err = DecodeNodes(nodes); err != nil {
+			return err
+		}
+	}
+	if nodes, ok, err := optionalString(ret, "nodes6"); err != nil {
+		return err
+	} else if ok {
+		if r.Return.Nodes6, err = DecodeNodes6(nodes); err != nil {
+			return err
+		}
+	}
+	if r.Return.Token, _, err = optionalString(ret, "token"); err != nil {
+		return err
+	}
+	if len(r.Return.Token) > MaxTokenLength {
+		return fmt.Errorf("Token is %d bytes long, at most %d allowed", len(r.Return.Token), MaxTokenLength)
+	}
+
+	if r.Return.Item, err = parseItem(ret); err != nil {
+		return err
+	}
+	if r.Return.Item != nil {
+		if err = checkItem(r.Return.Item); err != nil {
+			return err
+		}
+	}
+
+	if values, ok := ret["values"]; ok {
+		list, err := values.GetList()
+		if err != nil {
+			return fmt.Errorf("Key %q: %v", "values", err)
+		}
+		r.Return.Values = make([]Peer, len(list))
+		for i, v := range list {
+			s, err := v.GetString()
+			if err != nil {
+				return fmt.Errorf("Key %q: %v", "values", err)
+			}
+			if r.Return.Values[i], err = DecodePeer(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Error) parse(dict map[string]bencode.BnCode) error {
+	val, ok := dict["e"]
+	if !ok {
+		return fmt.Errorf("Required key %q is missing", "e")
+	}
+	list, err := val.GetList()
+	if err != nil {
+		return fmt.Errorf("Key %q: %v", "e", err)
+	}
+	if len(list) != 2 {
+		return fmt.Errorf("Error list must have 2 elements, got %d", len(list))
+	}
+	if e.Code, err = list[0].GetInt(); err != nil {
+		return fmt.Errorf("Key %q: %v", "e", err)
+	}
+	if e.Message, err = list[1].GetString(); err != nil {
+		return fmt.Errorf("Key %q: %v", "e", err)
+	}
+	return nil
+}
+
+func parseItem(dict map[string]bencode.BnCode) (*Item, error) {
+	v, ok := dict["v"]
+	if !ok {
+		return nil, nil
+	}
+	rc := &Item{V: v}
+
+	k, ok, err := optionalString(dict, "k")
+	if err != nil || !ok {
+		return rc, err
+	}
+	rc.K = ed25519.PublicKey(k)
+	sig, err := requireString(dict, "sig")
+	if err != nil {
+		return nil, err
+	}
+	rc.Sig = []byte(sig)
+	if rc.Seq, err = requireInt(dict, "seq"); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+func optionalIntPtr(dict map[string]bencode.BnCode, key string) (*int, error) {
+	i, ok, err := optionalInt(dict, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func optionalString(dict map[string]bencode.BnCode, key string) (string, bool, error) {
+	val, ok := dict[key]
+	if !ok {
+		return "", false, nil
+	}
+	s, err := val.GetString()
+	if err != nil {
+		return "", true, fmt.Errorf("Key %q: %v", key, err)
+	}
+	return s, true, nil
+}
+
+func optionalInt(dict map[string]bencode.BnCode, key string) (int, bool, error) {
+	val, ok := dict[key]
+	if !ok {
+		return 0, false, nil
+	}
+	i, err := val.GetInt()
+	if err != nil {
+		return 0, true, fmt.Errorf("Key %q: %v", key, err)
+	}
+	return i, true, nil
+}
+
+func requireString(dict map[string]bencode.BnCode, key string) (string, error) {
+	s, ok, err := optionalString(dict, key)
+	if err == nil && !ok {
+		err = fmt.Errorf("Required key %q is missing", key)
+	}
+	return s, err
+}
+
+func requireInt(dict map[string]bencode.BnCode, key string) (int, error) {
+	i, ok, err := optionalInt(dict, key)
+	if err == nil && !ok {
+		err = fmt.Errorf("Required key %q is missing", key)
+	}
+	return i, err
+}
+
+func requireDict(dict map[string]bencode.BnCode, key string) (map[string]bencode.BnCode, error) {
+	val, ok := dict[key]
+	if !ok {
+		return nil, fmt.Errorf("Required key %q is missing", key)
+	}
+	rc, err := val.GetDict()
+	if err != nil {
+		return nil, fmt.Errorf("Key %q: %v", key, err)
+	}
+	return rc, nil
+}
+
+func requireNodeID(dict map[string]bencode.BnCode, key string) (NodeID, error) {
+	s, err := requireString(dict, key)
+	if err != nil {
+		return NodeID{}, err
+	}
+	id, err := NodeIDFromString(s)
+	if err != nil {
+		return id, fmt.Errorf("Key %q: %v", key, err)
+	}
+	return id, nil
+}
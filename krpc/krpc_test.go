@@ -0,0 +1,214 @@
+package krpc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustID(s string) NodeID {
+	id, err := NodeIDFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Message
+		wantErr bool
+	}{
+		{
+			name: "Ping query",
+			data: "d1:ad2:id20:abcdefghij0123456789e1:q4:ping1:t2:aa1:y1:qe",
+			want: &Query{TransactionID: "aa", Method: MethodPing, Args: Args{
+				ID: mustID("abcdefghij0123456789"),
+			}},
+			wantErr: false,
+		},
+		{
+			name: "Ping response",
+			data: "d1:rd2:id20:mnopqrstuvwxyz123456e1:t2:aa1:y1:re",
+			want: &Response{TransactionID: "aa", Return: Return{
+				ID: mustID("mnopqrstuvwxyz123456"),
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "Error",
+			data:    "d1:eli201e23:A Generic Error Ocurrede1:t2:aa1:y1:ee",
+			want:    &Error{TransactionID: "aa", Code: ErrorGeneric, Message: "A Generic Error Ocurred"},
+			wantErr: false,
+		},
+		{
+			name: "Find node query",
+			data: "d1:ad2:id20:abcdefghij01234567896:target20:mnopqrstuvwxyz123456e1:q9:find_node1:t2:aa1:y1:qe",
+			want: &Query{TransactionID: "aa", Method: MethodFindNode, Args: Args{
+				ID:     mustID("abcdefghij0123456789"),
+				Target: mustID("mnopqrstuvwxyz123456"),
+			}},
+			wantErr: false,
+		},
+		{
+			name: "Get peers query",
+			data: "d1:ad2:id20:abcdefghij01234567899:info_hash20:mnopqrstuvwxyz123456e1:q9:get_peers1:t2:aa1:y1:qe",
+			want: &Query{TransactionID: "aa", Method: MethodGetPeers, Args: Args{
+				ID:       mustID("abcdefghij0123456789"),
+				InfoHash: mustID("mnopqrstuvwxyz123456"),
+			}},
+			wantErr: false,
+		},
+		{
+			name: "Announce peer query",
+			data: "d1:ad2:id20:abcdefghij012345678912:implied_porti1e9:info_hash20:mnopqrstuvwxyz1234564:porti6881e5:token8:aoeusnthe1:q13:announce_peer1:t2:aa1:y1:qe",
+			want: &Query{TransactionID: "aa", Method: MethodAnnouncePeer, Args: Args{
+				ID:          mustID("abcdefghij0123456789"),
+				InfoHash:    mustID("mnopqrstuvwxyz123456"),
+				Port:        6881,
+				ImpliedPort: true,
+				Token:       "aoeusnth",
+			}},
+			wantErr: false,
+		},
+		{
+			name: "Unknown method keeps the id only",
+			data: "d1:ad3:foo3:bar2:id20:abcdefghij0123456789e1:q6:vote_x1:t2:aa1:y1:qe",
+			want: &Query{TransactionID: "aa", Method: "vote_x", Args: Args{
+				ID: mustID("abcdefghij0123456789"),
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "Short node id",
+			data:    "d1:ad2:id3:abce1:q4:ping1:t2:aa1:y1:qe",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Missing transaction id",
+			data:    "d1:ad2:id20:abcdefghij0123456789e1:q4:ping1:y1:qe",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown message type",
+			data:    "d1:t2:aa1:y1:xe",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Trailing data",
+			data:    "d1:rd2:id20:mnopqrstuvwxyz123456e1:t2:aa1:y1:rei1e",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Truncated compact nodes",
+			data:    "d1:rd2:id20:mnopqrstuvwxyz1234565:nodes3:abce1:t2:aa1:y1:re",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Oversized message",
+			data:    "d1:t2:aa1:y1:r1:x1500:" + strings.Repeat("x", 1500) + "e",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unmarshal([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Announce peer query",
+			msg: &Query{TransactionID: "aa", Method: MethodAnnouncePeer, Args: Args{
+				ID:          mustID("abcdefghij0123456789"),
+				InfoHash:    mustID("mnopqrstuvwxyz123456"),
+				Port:        6881,
+				ImpliedPort: true,
+				Token:       "aoeusnth",
+			}},
+			want:    "d1:ad2:id20:abcdefghij012345678912:implied_porti1e9:info_hash20:mnopqrstuvwxyz1234564:porti6881e5:token8:aoeusnthe1:q13:announce_peer1:t2:aa1:y1:qe",
+			wantErr: false,
+		},
+		{
+			name: "Get peers response with values",
+			msg: &Response{TransactionID: "aa", Return: Return{
+				ID:    mustID("abcdefghij0123456789"),
+				Token: "aoeusnth",
+				Values: []Peer{
+					{IP: []byte("axje"), Port: 0x2e75},
+					{IP: []byte("idht"), Port: 0x6e6d},
+				},
+			}},
+			want:    "d1:rd2:id20:abcdefghij01234567895:token8:aoeusnth6:valuesl6:axje.u6:idhtnmee1:t2:aa1:y1:re",
+			wantErr: false,
+		},
+		{
+			name:    "Error with version",
+			msg:     &Error{TransactionID: "aa", Version: "UT01", Code: ErrorMethodUnknown, Message: "Method Unknown"},
+			want:    "d1:eli204e14:Method Unknowne1:t2:aa1:v4:UT011:y1:ee",
+			wantErr: false,
+		},
+		{
+			name:    "Announce without token",
+			msg:     &Query{TransactionID: "aa", Method: MethodAnnouncePeer},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Empty transaction id",
+			msg:     &Query{Method: MethodPing},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Transaction id too long",
+			msg:     &Error{TransactionID: strings.Repeat("t", MaxTransactionIDLength+1)},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionIDs(t *testing.T) {
+	var ids TransactionIDs
+	if got := ids.Next(); got != "\x00\x00" {
+		t.Errorf("Next() = %q, want %q", got, "\x00\x00")
+	}
+	if got := ids.Next(); got != "\x00\x01" {
+		t.Errorf("Next() = %q, want %q", got, "\x00\x01")
+	}
+}
@@ -0,0 +1,162 @@
+package krpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	// NodeIDLength is the size of a DHT node id and of an info hash
+	NodeIDLength = 20
+	// CompactNodeInfoLength is the size of a single IPv4 compact node info entry
+	CompactNodeInfoLength = NodeIDLength + net.IPv4len + 2
+	// CompactNodeInfo6Length is the size of a single IPv6 compact node info entry
+	CompactNodeInfo6Length = NodeIDLength + net.IPv6len + 2
+	// CompactPeerLength is the size of a single IPv4 compact peer entry
+	CompactPeerLength = net.IPv4len + 2
+	// CompactPeer6Length is the size of a single IPv6 compact peer entry
+	CompactPeer6Length = net.IPv6len + 2
+)
+
+// NodeID is a 160 bit identifier used both for DHT nodes and info hashes.
+type NodeID [NodeIDLength]byte
+
+// NodeIDFromString converts the raw 20 byte string into NodeID.
+//
+// Returns error if the string has a wrong length.
+func NodeIDFromString(s string) (NodeID, error) {
+	var id NodeID
+	if len(s) != NodeIDLength {
+		return id, fmt.Errorf("Node id must be %d bytes long, got %d", NodeIDLength, len(s))
+	}
+	copy(id[:], s)
+	return id, nil
+}
+
+// String returns the raw bytes of the id, as they appear on the wire.
+func (id NodeID) String() string {
+	return string(id[:])
+}
+
+// NodeInfo is a DHT contact: node id together with its UDP address.
+type NodeInfo struct {
+	ID   NodeID
+	IP   net.IP
+	Port int
+}
+
+// Peer is an address of a peer participating in a torrent swarm.
+type Peer struct {
+	IP   net.IP
+	Port int
+}
+
+func putAddr(dst []byte, ip net.IP, port int) error {
+	if port < 0 || port > 0xffff {
+		return fmt.Errorf("Port %d is out of range", port)
+	}
+	copy(dst, ip)
+	binary.BigEndian.PutUint16(dst[len(ip):], uint16(port))
+	return nil
+}
+
+func getAddr(src []byte) (net.IP, int) {
+	ipLen := len(src) - 2
+	ip := make(net.IP, ipLen)
+	copy(ip, src[:ipLen])
+	return ip, int(binary.BigEndian.Uint16(src[ipLen:]))
+}
+
+// EncodeNodes flattens IPv4 contacts into the compact node info format (26 bytes per node).
+//
+// Returns error if any of the nodes does not have an IPv4 address.
+func EncodeNodes(nodes []NodeInfo) (string, error) {
+	rc := make([]byte, len(nodes)*CompactNodeInfoLength)
+	for i, n := range nodes {
+		ip := n.IP.To4()
+		if ip == nil {
+			return "", fmt.Errorf("Node %d does not have an IPv4 address", i)
+		}
+		entry := rc[i*CompactNodeInfoLength:]
+		copy(entry, n.ID[:])
+		if err := putAddr(entry[NodeIDLength:], ip, n.Port); err != nil {
+			return "", err
+		}
+	}
+	return string(rc), nil
+}
+
+// EncodeNodes6 flattens IPv6 contacts into the compact node info format (38 bytes per node).
+//
+// Returns error if any of the nodes does not have an IPv6 address.
+func EncodeNodes6(nodes []NodeInfo) (string, error) {
+	rc := make([]byte, len(nodes)*CompactNodeInfo6Length)
+	for i, n := range nodes {
+		if len(n.IP) != net.IPv6len || n.IP.To4() != nil {
+			return "", fmt.Errorf("Node %d does not have an IPv6 address", i)
+		}
+		entry := rc[i*CompactNodeInfo6Length:]
+		copy(entry, n.ID[:])
+		if err := putAddr(entry[NodeIDLength:], n.IP, n.Port); err != nil {
+			return "", err
+		}
+	}
+	return string(rc), nil
+}
+
+func decodeNodes(src string, size int) ([]NodeInfo, error) {
+	if len(src)%size != 0 {
+		return nil, fmt.Errorf("Compact node info length %d is not a multiple of %d", len(src), size)
+	}
+	rc := make([]NodeInfo, 0, len(src)/size)
+	for i := 0; i < len(src); i += size {
+		var n NodeInfo
+		copy(n.ID[:], src[i:i+NodeIDLength])
+		n.IP, n.Port = getAddr([]byte(src[i+NodeIDLength : i+size]))
+		rc = append(rc, n)
+	}
+	return rc, nil
+}
+
+// DecodeNodes parses the IPv4 compact node info string.
+//
+// Returns error if the length of the string is not a multiple of 26.
+func DecodeNodes(src string) ([]NodeInfo, error) {
+	return decodeNodes(src, CompactNodeInfoLength)
+}
+
+// DecodeNodes6 parses the IPv6 compact node info string.
+//
+// Returns error if the length of the string is not a multiple of 38.
+func DecodeNodes6(src string) ([]NodeInfo, error) {
+	return decodeNodes(src, CompactNodeInfo6Length)
+}
+
+// EncodePeer flattens the peer address into the compact peer format,
+// 6 bytes for IPv4 and 18 bytes for IPv6 addresses.
+func EncodePeer(p Peer) (string, error) {
+	ip := p.IP.To4()
+	if ip == nil {
+		ip = p.IP.To16()
+	}
+	if ip == nil {
+		return "", fmt.Errorf("Peer does not have a valid IP address")
+	}
+	rc := make([]byte, len(ip)+2)
+	if err := putAddr(rc, ip, p.Port); err != nil {
+		return "", err
+	}
+	return string(rc), nil
+}
+
+// DecodePeer parses a single compact peer entry.
+//
+// Returns error if the entry is neither 6 nor 18 bytes long.
+func DecodePeer(src string) (Peer, error) {
+	if len(src) != CompactPeerLength && len(src) != CompactPeer6Length {
+		return Peer{}, fmt.Errorf("Compact peer must be %d or %d bytes long, got %d", CompactPeerLength, CompactPeer6Length, len(src))
+	}
+	ip, port := getAddr([]byte(src))
+	return Peer{IP: ip, Port: port}, nil
+}
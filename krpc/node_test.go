@@ -0,0 +1,151 @@
+package krpc
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testID(c byte) NodeID {
+	var id NodeID
+	for i := range id {
+		id[i] = c
+	}
+	return id
+}
+
+func TestEncodeNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodes   []NodeInfo
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Positive test case",
+			nodes:   []NodeInfo{{ID: testID('a'), IP: net.IPv4(1, 2, 3, 4), Port: 6881}},
+			want:    strings.Repeat("a", 20) + "\x01\x02\x03\x04\x1a\xe1",
+			wantErr: false,
+		},
+		{
+			name:    "No nodes",
+			nodes:   nil,
+			want:    "",
+			wantErr: false,
+		},
+		{
+			name:    "IPv6 node",
+			nodes:   []NodeInfo{{ID: testID('a'), IP: net.ParseIP("::1"), Port: 6881}},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Port out of range",
+			nodes:   []NodeInfo{{ID: testID('a'), IP: net.IPv4(1, 2, 3, 4), Port: 70000}},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeNodes(tt.nodes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EncodeNodes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("EncodeNodes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeNodes6(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []NodeInfo
+		wantErr bool
+	}{
+		{
+			name:    "Positive test case",
+			src:     strings.Repeat("b", 20) + strings.Repeat("\x00", 15) + "\x01\x00\x50",
+			want:    []NodeInfo{{ID: testID('b'), IP: net.ParseIP("::1"), Port: 80}},
+			wantErr: false,
+		},
+		{
+			name:    "Truncated entry",
+			src:     strings.Repeat("b", 37),
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeNodes6(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeNodes6() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeNodes6() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeNodes6RoundTrip(t *testing.T) {
+	nodes := []NodeInfo{
+		{ID: testID('c'), IP: net.ParseIP("2001:db8::1"), Port: 1},
+		{ID: testID('d'), IP: net.ParseIP("fe80::2"), Port: 65535},
+	}
+	enc, err := EncodeNodes6(nodes)
+	if err != nil {
+		t.Fatalf("EncodeNodes6() error = %v", err)
+	}
+	if len(enc) != 2*CompactNodeInfo6Length {
+		t.Fatalf("EncodeNodes6() length = %d, want %d", len(enc), 2*CompactNodeInfo6Length)
+	}
+	got, err := DecodeNodes6(enc)
+	if err != nil {
+		t.Fatalf("DecodeNodes6() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, nodes) {
+		t.Errorf("DecodeNodes6() = %v, want %v", got, nodes)
+	}
+}
+
+func TestDecodePeer(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    Peer
+		wantErr bool
+	}{
+		{
+			name:    "IPv4 peer",
+			src:     "\x0a\x00\x00\x01\x1a\xe1",
+			want:    Peer{IP: net.IP{10, 0, 0, 1}, Port: 6881},
+			wantErr: false,
+		},
+		{
+			name:    "Wrong length",
+			src:     "\x0a\x00\x00\x01\x1a",
+			want:    Peer{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePeer(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodePeer() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodePeer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
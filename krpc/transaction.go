@@ -0,0 +1,25 @@
+package krpc
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// TransactionIDs hands out 2 byte transaction ids for outgoing queries.
+// The zero value is ready to use and is safe for concurrent use.
+type TransactionIDs struct {
+	mu   sync.Mutex
+	next uint16
+}
+
+// Next returns the following transaction id, wrapping around after 65536 queries.
+func (t *TransactionIDs) Next() string {
+	t.mu.Lock()
+	id := t.next
+	t.next++
+	t.mu.Unlock()
+
+	var rc [2]byte
+	binary.BigEndian.PutUint16(rc[:], id)
+	return string(rc[:])
+}
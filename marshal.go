@@ -0,0 +1,365 @@
+package bencode
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Marshaler is implemented by types that provide their own wire format.
+// MarshalBencode must return exactly one valid bencoded value.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode their own wire format.
+// UnmarshalBencode receives the raw bytes of a single bencoded value and must copy them if it keeps them.
+type Unmarshaler interface {
+	UnmarshalBencode([]byte) error
+}
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	bnCodeType          = reflect.TypeOf(BnCode{})
+)
+
+// field is a struct field that takes part in marshalling.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// typeFields returns the marshalled fields of the struct type, sorted by their key.
+//
+// Fields are named by the "bencode" tag, or by the Go field name if the tag has no name.
+// The tag "-" skips the field, the "omitempty" option skips zero values.
+// Fields of embedded structs without a tag are promoted, unless shadowed by a less nested field.
+// Fields sharing a name at the same depth are dropped, unless exactly one of them is tagged.
+// Like in encoding/json, every embedded struct type is expanded only once, which stops recursive embedding.
+func typeFields(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	type candidate struct {
+		field
+		depth  int
+		tagged bool
+	}
+	type embedding struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var candidates []candidate
+	visited := make(map[reflect.Type]bool)
+	current := []embedding{{typ: t}}
+	for depth := 0; len(current) > 0; depth++ {
+		var next []embedding
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				tag := sf.Tag.Get("bencode")
+				if tag == "-" {
+					continue
+				}
+				name, opts := tag, ""
+				if idx := strings.IndexByte(tag, ','); idx >= 0 {
+					name, opts = tag[:idx], tag[idx+1:]
+				}
+				index := append(append([]int{}, e.index...), i)
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					next = append(next, embedding{typ: ft, index: index})
+					continue
+				}
+				if sf.PkgPath != "" {
+					// unexported
+					continue
+				}
+				tagged := name != ""
+				if !tagged {
+					name = sf.Name
+				}
+				candidates = append(candidates, candidate{
+					field:  field{name: name, index: index, omitEmpty: opts == "omitempty"},
+					depth:  depth,
+					tagged: tagged,
+				})
+			}
+		}
+		current = next
+	}
+
+	// the shallowest field of every name wins, tagged ones first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.depth != b.depth {
+			return a.depth < b.depth
+		}
+		return a.tagged && !b.tagged
+	})
+	var fields []field
+	for i := 0; i < len(candidates); {
+		j := i + 1
+		for j < len(candidates) && candidates[j].name == candidates[i].name {
+			j++
+		}
+		first := candidates[i]
+		if j == i+1 || candidates[i+1].depth > first.depth || (first.tagged && !candidates[i+1].tagged) {
+			fields = append(fields, first.field)
+		}
+		i = j
+	}
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// fieldByIndex returns the field value, allocating nil embedded pointers if alloc is set.
+// The returned value is invalid if a nil embedded pointer is found and alloc is not set.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// wrapPath prefixes the error with the location of the node, unless it is the root.
+func wrapPath(path Path, err error) error {
+	if len(path) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %v", path, err)
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = strconv.AppendInt(dst, int64(len(s)), 10)
+	dst = append(dst, ':')
+	return append(dst, s...)
+}
+
+// Marshal encodes the Go value into bencode.
+//
+// Integers and booleans are encoded as ints, strings, byte slices and byte arrays as strings,
+// slices and arrays as lists, maps and structs as dictionaries. Map keys have to be strings, integers
+// or implement encoding.TextMarshaler, keys of string kinds are used as they are like in encoding/json. Struct fields follow the rules of the "bencode" tag, see typeFields.
+// BnCode values are encoded with Encode and types implementing Marshaler encode themselves.
+//
+// Nil pointers and interfaces are skipped inside structs and maps, elsewhere they are an error,
+// since bencode has no null value.
+func Marshal(v interface{}) ([]byte, error) {
+	return appendValue(nil, reflect.ValueOf(v), Path{})
+}
+
+func appendValue(dst []byte, v reflect.Value, path Path) ([]byte, error) {
+	if !v.IsValid() {
+		return nil, wrapPath(path, fmt.Errorf("Can not marshal nil value"))
+	}
+
+	if v.Type().Implements(marshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil, wrapPath(path, fmt.Errorf("Can not marshal nil %v", v.Type()))
+		}
+		return appendMarshaler(dst, v.Interface().(Marshaler), path)
+	}
+	if v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		if !v.CanAddr() {
+			// make a copy to be able to call the pointer receiver
+			tmp := reflect.New(v.Type()).Elem()
+			tmp.Set(v)
+			v = tmp
+		}
+		return appendMarshaler(dst, v.Addr().Interface().(Marshaler), path)
+	}
+	if v.Type() == bnCodeType {
+		enc, err := Encode(v.Interface().(BnCode))
+		if err != nil {
+			return nil, wrapPath(path, err)
+		}
+		return append(dst, enc...), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(dst, "i1e"...), nil
+		}
+		return append(dst, "i0e"...), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst = append(dst, 'i')
+		dst = strconv.AppendInt(dst, v.Int(), 10)
+		return append(dst, 'e'), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		dst = append(dst, 'i')
+		dst = strconv.AppendUint(dst, v.Uint(), 10)
+		return append(dst, 'e'), nil
+	case reflect.String:
+		return appendString(dst, v.String()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendString(dst, string(v.Bytes())), nil
+		}
+		return appendList(dst, v, path)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return appendString(dst, string(b)), nil
+		}
+		return appendList(dst, v, path)
+	case reflect.Map:
+		return appendMap(dst, v, path)
+	case reflect.Struct:
+		return appendStruct(dst, v, path)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, wrapPath(path, fmt.Errorf("Can not marshal nil %v", v.Type()))
+		}
+		return appendValue(dst, v.Elem(), path)
+	default:
+		return nil, wrapPath(path, fmt.Errorf("Can not marshal %v", v.Type()))
+	}
+}
+
+func appendMarshaler(dst []byte, m Marshaler, path Path) ([]byte, error) {
+	enc, err := m.MarshalBencode()
+	if err != nil {
+		return nil, wrapPath(path, err)
+	}
+	if _, err = Parse(enc); err != nil {
+		return nil, wrapPath(path, fmt.Errorf("MarshalBencode returned invalid data: %v", err))
+	}
+	return append(dst, enc...), nil
+}
+
+func appendList(dst []byte, v reflect.Value, path Path) ([]byte, error) {
+	var err error
+	dst = append(dst, 'l')
+	for i := 0; i < v.Len(); i++ {
+		if dst, err = appendValue(dst, v.Index(i), path.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, 'e'), nil
+}
+
+func isNil(v reflect.Value) bool {
+	return (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+// mapKey converts the map key to the dictionary key.
+// Like encoding/json, keys of string kinds are used as they are, even if they implement encoding.TextMarshaler.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if k.Type().Implements(textMarshalerType) {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", fmt.Errorf("Nil map key")
+		}
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("Unsupported map key type %v", k.Type())
+}
+
+func appendMap(dst []byte, v reflect.Value, path Path) ([]byte, error) {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, err := mapKey(iter.Key())
+		if err != nil {
+			return nil, wrapPath(path, err)
+		}
+		if isNil(iter.Value()) {
+			continue
+		}
+		if _, ok := values[k]; ok {
+			return nil, wrapPath(path, fmt.Errorf("Duplicate dictionary key %q", k))
+		}
+		keys = append(keys, k)
+		values[k] = iter.Value()
+	}
+	sort.Strings(keys)
+
+	var err error
+	dst = append(dst, 'd')
+	for _, k := range keys {
+		dst = appendString(dst, k)
+		if dst, err = appendValue(dst, values[k], path.Key(k)); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, 'e'), nil
+}
+
+func appendStruct(dst []byte, v reflect.Value, path Path) ([]byte, error) {
+	var err error
+	dst = append(dst, 'd')
+	for _, f := range typeFields(v.Type()) {
+		fv := fieldByIndex(v, f.index, false)
+		if !fv.IsValid() || isNil(fv) || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		dst = appendString(dst, f.name)
+		if dst, err = appendValue(dst, fv, path.Key(f.name)); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, 'e'), nil
+}
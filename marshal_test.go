@@ -0,0 +1,234 @@
+package bencode
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// compactPeers has a custom wire format, a single string of 6 bytes per peer
+type compactPeers []string
+
+func (p compactPeers) MarshalBencode() ([]byte, error) {
+	s := strings.Join(p, "")
+	return []byte(strconv.Itoa(len(s)) + ":" + s), nil
+}
+
+func (p *compactPeers) UnmarshalBencode(data []byte) error {
+	var s string
+	if err := Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if len(s)%6 != 0 {
+		return errors.New("Invalid compact peers")
+	}
+	*p = nil
+	for i := 0; i < len(s); i += 6 {
+		*p = append(*p, s[i:i+6])
+	}
+	return nil
+}
+
+// version is marshalled through a pointer receiver
+type version struct {
+	major, minor int
+}
+
+func (v *version) MarshalBencode() ([]byte, error) {
+	return Marshal([]int{v.major, v.minor})
+}
+
+func (v *version) UnmarshalBencode(data []byte) error {
+	var parts []int
+	if err := Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	if len(parts) != 2 {
+		return errors.New("Invalid version")
+	}
+	v.major, v.minor = parts[0], parts[1]
+	return nil
+}
+
+// upperKey is a dictionary key with a text representation
+type upperKey string
+
+func (k upperKey) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(k))), nil
+}
+
+func (k *upperKey) UnmarshalText(text []byte) error {
+	*k = upperKey(strings.ToLower(string(text)))
+	return nil
+}
+
+// hexKey is an integer dictionary key with a text representation
+type hexKey uint16
+
+func (k hexKey) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(k), 16)), nil
+}
+
+type brokenMarshaler struct{}
+
+func (brokenMarshaler) MarshalBencode() ([]byte, error) {
+	return []byte("i1"), nil
+}
+
+type Common struct {
+	Comment string `bencode:"comment,omitempty"`
+}
+
+type torrent struct {
+	Common
+	Announce string            `bencode:"announce"`
+	Private  bool              `bencode:"private"`
+	Length   int64             `bencode:"length,omitempty"`
+	Hash     [4]byte           `bencode:"hash"`
+	Tiers    [][]string        `bencode:"announce-list,omitempty"`
+	Peers    compactPeers      `bencode:"peers"`
+	Version  version           `bencode:"v"`
+	Extra    map[string]BnCode `bencode:"extra,omitempty"`
+	Skipped  string            `bencode:"-"`
+	Nil      *int              `bencode:"nil"`
+	internal int
+}
+
+// recursive embeds itself, its type must be expanded only once
+type recursive struct {
+	*recursive
+	A int
+}
+
+type ambiguousA struct {
+	Name string `bencode:"name"`
+	Size int    `bencode:"size"`
+}
+
+type ambiguousB struct {
+	Name string `bencode:"name"`
+}
+
+type ambiguous struct {
+	ambiguousA
+	ambiguousB
+	Size int
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Struct with hooks",
+			v: torrent{
+				Common:   Common{Comment: "c"},
+				Announce: "url",
+				Private:  true,
+				Hash:     [4]byte{'a', 'b', 'c', 'd'},
+				Peers:    compactPeers{"aaaaaa", "bbbbbb"},
+				Version:  version{1, 2},
+				Skipped:  "x",
+				internal: 1,
+			},
+			want:    "d8:announce3:url7:comment1:c4:hash4:abcd5:peers12:aaaaaabbbbbb7:privatei1e1:vli1ei2eee",
+			wantErr: false,
+		},
+		{
+			name:    "Map with text keys",
+			v:       map[hexKey]int{255: 1, 16: 2},
+			want:    "d2:10i2e2:ffi1ee",
+			wantErr: false,
+		},
+		{
+			name:    "Map with string keys implementing TextMarshaler",
+			v:       map[upperKey]int{"b": 1, "a": 2},
+			want:    "d1:ai2e1:bi1ee",
+			wantErr: false,
+		},
+		{
+			name:    "Map with int keys",
+			v:       map[int]string{10: "x", 2: "y"},
+			want:    "d2:101:x1:21:ye",
+			wantErr: false,
+		},
+		{
+			name:    "BnCode value",
+			v:       []interface{}{BnCode{State: BnInt, Value: 1}, "a", []byte("b"), uint8(3)},
+			want:    "li1e1:a1:bi3ee",
+			wantErr: false,
+		},
+		{
+			name:    "Nil",
+			v:       nil,
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Float",
+			v:       map[string]float64{"a": 1},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Nil marshaler interface",
+			v:       []Marshaler{nil},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Recursive embedding",
+			v:       recursive{A: 1},
+			want:    "d1:Ai1ee",
+			wantErr: false,
+		},
+		{
+			name: "Duplicate tags",
+			v: struct {
+				A int `bencode:"a"`
+				B int `bencode:"a"`
+				C int `bencode:"c"`
+			}{1, 2, 3},
+			want:    "d1:ci3ee",
+			wantErr: false,
+		},
+		{
+			name:    "Ambiguous embedded fields",
+			v:       ambiguous{ambiguousA{"a", 1}, ambiguousB{"b"}, 2},
+			want:    "d4:Sizei2e4:sizei1ee",
+			wantErr: false,
+		},
+		{
+			name:    "Invalid custom encoding",
+			v:       []brokenMarshaler{{}},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && got != nil {
+				t.Errorf("Marshal() = %q along with an error, want nil", got)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal_ErrorPath(t *testing.T) {
+	_, err := Marshal(map[string][]interface{}{"a": {1, 1.5}})
+	if err == nil || !strings.HasPrefix(err.Error(), "a[1]: ") {
+		t.Errorf("Marshal() error = %v, want it to start with the path", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package bencode
+
+import (
+	"io"
+	"os"
+)
+
+func mapFile(f *os.File) ([]byte, error) {
+	return io.ReadAll(f)
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,146 @@
+package bencode
+
+import (
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Printer formats BnCode trees in a readable and deterministic form.
+//
+// Ints are printed as numbers, text strings quoted, binary strings in hex with a 0x prefix.
+// Lists are enclosed in brackets and dictionaries in braces, with one element per line.
+// Dictionary keys are printed in sorted order, OrderedDict entries in their own order.
+type Printer struct {
+	// Indent is the indentation of every nesting level, two spaces if empty
+	Indent string
+	// MaxStringLength is the number of bytes of a string printed before it is truncated, 0 disables truncation
+	MaxStringLength int
+}
+
+// DefaultPrinter is used by BnCode.String.
+var DefaultPrinter = Printer{Indent: "  ", MaxStringLength: 64}
+
+// String formats the node with DefaultPrinter.
+func (obj BnCode) String() string {
+	return DefaultPrinter.Sprint(obj)
+}
+
+// Sprint returns the formatted node.
+func (p Printer) Sprint(node BnCode) string {
+	var sb strings.Builder
+	p.print(&sb, node, "")
+	return sb.String()
+}
+
+// Fprint writes the formatted node to w.
+func (p Printer) Fprint(w io.Writer, node BnCode) error {
+	_, err := io.WriteString(w, p.Sprint(node))
+	return err
+}
+
+// isBinary tells whether the string is better shown in hex, that is it is not valid UTF-8
+// or it holds control characters besides the common whitespace.
+func isBinary(s string) bool {
+	if !utf8.ValidString(s) {
+		return true
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Printer) printString(sb *strings.Builder, s string) {
+	truncated := p.MaxStringLength > 0 && len(s) > p.MaxStringLength
+	shown := s
+	if truncated {
+		shown = s[:p.MaxStringLength]
+	}
+
+	if isBinary(s) {
+		sb.WriteString("0x")
+		sb.WriteString(hex.EncodeToString([]byte(shown)))
+	} else {
+		// do not cut a character in half
+		for truncated && !utf8.ValidString(shown) {
+			shown = shown[:len(shown)-1]
+		}
+		sb.WriteString(strconv.Quote(shown))
+	}
+	if truncated {
+		sb.WriteString("... (" + strconv.Itoa(len(s)) + " bytes)")
+	}
+}
+
+func (p Printer) print(sb *strings.Builder, node BnCode, indent string) {
+	step := p.Indent
+	if step == "" {
+		step = "  "
+	}
+
+	switch node.State {
+	case BnInt:
+		if i, err := node.GetInt(); err == nil {
+			sb.WriteString(strconv.Itoa(i))
+			return
+		}
+	case BnString:
+		if s, ok := node.Value.(StreamedString); ok {
+			sb.WriteString("<streamed " + strconv.Itoa(s.Length) + " bytes>")
+			return
+		}
+		if s, err := node.GetString(); err == nil {
+			p.printString(sb, s)
+			return
+		}
+	case BnList:
+		list, err := node.GetList()
+		if err != nil {
+			break
+		}
+		if len(list) == 0 {
+			sb.WriteString("[]")
+			return
+		}
+		sb.WriteString("[\n")
+		for _, v := range list {
+			sb.WriteString(indent + step)
+			p.print(sb, v, indent+step)
+			sb.WriteString("\n")
+		}
+		sb.WriteString(indent + "]")
+		return
+	case BnDict:
+		var entries OrderedDict
+		if ordered, ok := node.Value.(OrderedDict); ok {
+			entries = ordered
+		} else if dict, err := node.GetDict(); err == nil {
+			for _, k := range sortedKeys(dict) {
+				entries = append(entries, DictEntry{Key: k, Value: dict[k]})
+			}
+		} else {
+			break
+		}
+		if len(entries) == 0 {
+			sb.WriteString("{}")
+			return
+		}
+		sb.WriteString("{\n")
+		for _, e := range entries {
+			sb.WriteString(indent + step)
+			p.printString(sb, e.Key)
+			sb.WriteString(": ")
+			p.print(sb, e.Value, indent+step)
+			sb.WriteString("\n")
+		}
+		sb.WriteString(indent + "}")
+		return
+	}
+	sb.WriteString("<invalid " + node.State.String() + ">")
+}
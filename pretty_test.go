@@ -0,0 +1,108 @@
+package bencode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestState_String(t *testing.T) {
+	tests := []struct {
+		state State
+		want  string
+	}{
+		{state: BnInt, want: "int"},
+		{state: BnString, want: "string"},
+		{state: BnList, want: "list"},
+		{state: BnDict, want: "dict"},
+		{state: 7, want: "State(7)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.state.String(); got != tt.want {
+				t.Errorf("State.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrinter_Sprint(t *testing.T) {
+	node := BnCode{State: BnDict, Value: map[string]BnCode{
+		"announce": {State: BnString, Value: "http://tracker/announce"},
+		"info": {State: BnDict, Value: map[string]BnCode{
+			"length": {State: BnInt, Value: -42},
+			"name":   {State: BnString, Value: "héllo wörld"},
+			"pieces": {State: BnString, Value: "\x00\x01\xfe\xff\x10\x20"},
+			"files":  {State: BnList, Value: []BnCode{{State: BnString, Value: "a"}, {State: BnList, Value: []BnCode{}}}},
+			"extra":  {State: BnDict, Value: OrderedDict{{Key: "z", Value: BnCode{State: BnInt, Value: 1}}, {Key: "a", Value: BnCode{State: BnDict, Value: map[string]BnCode{}}}}},
+		}},
+		"broken": {State: BnList, Value: "x"},
+		"layers": {State: BnString, Value: StreamedString{Length: 100}},
+	}}
+
+	tests := []struct {
+		name    string
+		printer Printer
+		want    string
+	}{
+		{
+			name:    "Default indentation",
+			printer: Printer{},
+			want: `{
+  "announce": "http://tracker/announce"
+  "broken": <invalid list>
+  "info": {
+    "extra": {
+      "z": 1
+      "a": {}
+    }
+    "files": [
+      "a"
+      []
+    ]
+    "length": -42
+    "name": "héllo wörld"
+    "pieces": 0x0001feff1020
+  }
+  "layers": <streamed 100 bytes>
+}`,
+		},
+		{
+			name:    "Truncated strings",
+			printer: Printer{Indent: "\t", MaxStringLength: 2},
+			want: "{\n" +
+				"\t\"an\"... (8 bytes): \"ht\"... (23 bytes)\n" +
+				"\t\"br\"... (6 bytes): <invalid list>\n" +
+				"\t\"in\"... (4 bytes): {\n" +
+				"\t\t\"ex\"... (5 bytes): {\n" +
+				"\t\t\t\"z\": 1\n" +
+				"\t\t\t\"a\": {}\n" +
+				"\t\t}\n" +
+				"\t\t\"fi\"... (5 bytes): [\n" +
+				"\t\t\t\"a\"\n" +
+				"\t\t\t[]\n" +
+				"\t\t]\n" +
+				"\t\t\"le\"... (6 bytes): -42\n" +
+				"\t\t\"na\"... (4 bytes): \"h\"... (13 bytes)\n" +
+				"\t\t\"pi\"... (6 bytes): 0x0001... (6 bytes)\n" +
+				"\t}\n" +
+				"\t\"la\"... (6 bytes): <streamed 100 bytes>\n" +
+				"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.printer.Sprint(node); got != tt.want {
+				t.Errorf("Printer.Sprint() = \n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := DefaultPrinter.Fprint(&buf, node); err != nil || buf.String() != node.String() {
+		t.Errorf("Printer.Fprint() = %s, %v", buf.String(), err)
+	}
+	if !strings.Contains(BnCode{State: BnString, Value: strings.Repeat("x", 100)}.String(), "(100 bytes)") {
+		t.Errorf("BnCode.String() did not truncate a long string")
+	}
+}
@@ -0,0 +1,195 @@
+package bencode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// pushbackReader is a buffered stream that allows to put back bytes which were already read.
+type pushbackReader struct {
+	pending []byte
+	// pos is the read index of pending
+	pos    int
+	reader *bufio.Reader
+}
+
+func (p *pushbackReader) ReadByte() (byte, error) {
+	if p.pos < len(p.pending) {
+		b := p.pending[p.pos]
+		p.pos++
+		return b, nil
+	}
+	return p.reader.ReadByte()
+}
+
+func (p *pushbackReader) Read(b []byte) (int, error) {
+	if p.pos < len(p.pending) {
+		n := copy(b, p.pending[p.pos:])
+		p.pos += n
+		return n, nil
+	}
+	return p.reader.Read(b)
+}
+
+// unread puts the bytes back in front of the stream. Bytes read from pending are put back by moving
+// the read index, so rescanning a corrupt region does not copy it again. The bytes are only prepended
+// when the read index is too close to the start to hold them.
+func (p *pushbackReader) unread(b []byte) {
+	switch {
+	case p.pos == len(p.pending):
+		p.pending = append(p.pending[:0], b...)
+		p.pos = 0
+	case p.pos >= len(b):
+		p.pos -= len(b)
+		copy(p.pending[p.pos:], b)
+	default:
+		p.pending = append(append([]byte{}, b...), p.pending[p.pos:]...)
+		p.pos = 0
+	}
+}
+
+// RecordReader iterates over bencoded records stored back to back in a stream, such as a log file.
+//
+//	records := NewRecordReader(f)
+//	for records.Next() {
+//		handle(records.Value())
+//	}
+//	if err := records.Err(); err != nil {
+//		...
+//	}
+type RecordReader struct {
+	src     *pushbackReader
+	decoder *Decoder
+	resync  bool
+
+	value   BnCode
+	err     error
+	offset  int64
+	start   int64
+	skipped int64
+	// truncated is the error of a record cut off by the end of the stream, reported if nothing decodes after it
+	truncated error
+}
+
+// NewRecordReader creates a reader of the records of the stream.
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{src: &pushbackReader{reader: bufio.NewReader(r)}, decoder: NewDecoder(nil)}
+}
+
+// Resync makes the reader skip corrupt records instead of failing. After a record fails to decode,
+// the reader drops bytes from its start up to the next 'd' and tries again there, so records are
+// expected to be dictionaries. Skipped reports the number of bytes dropped this way.
+// A stream that ends in the middle of a record still fails with io.ErrUnexpectedEOF once nothing
+// after that record could be decoded, so a cut off stream can be told from a complete one.
+//
+// Every byte of the failed attempt is kept in memory until it is retried, combine with
+// Decoder().MaxStringLength to bound the damage of a corrupt length prefix.
+func (r *RecordReader) Resync() {
+	r.resync = true
+}
+
+// Decoder returns the decoder used for the records, to set its options before the first call to Next.
+func (r *RecordReader) Decoder() *Decoder {
+	return r.decoder
+}
+
+// Next decodes the next record and reports whether there is one.
+// It returns false at the end of the stream or on error, which is then returned by Err.
+func (r *RecordReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	for {
+		b, err := r.src.ReadByte()
+		if err == io.EOF {
+			r.err = r.truncated
+			return false
+		} else if err != nil {
+			r.err = err
+			return false
+		}
+
+		rec := &recorder{reader: r.src, buf: []byte{b}}
+		r.decoder.Reset(rec)
+		node, err := r.decoder.decode(b)
+		if err == nil {
+			r.value, r.start = node, r.offset
+			r.offset += int64(len(rec.buf))
+			r.truncated = nil
+			return true
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		err = fmt.Errorf("Offset %d: %w", r.offset, err)
+		if !r.resync {
+			r.err = err
+			return false
+		}
+		r.truncated = nil
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			r.truncated = err
+		}
+
+		// retry from the next dictionary start after the first byte of the failed record
+		r.src.unread(rec.buf[1:])
+		r.offset++
+		r.skipped++
+		for {
+			if b, err = r.src.ReadByte(); err != nil {
+				break
+			}
+			if b == 'd' {
+				r.src.unread([]byte{b})
+				break
+			}
+			r.offset++
+			r.skipped++
+		}
+	}
+}
+
+// Value returns the record decoded by the last call to Next.
+func (r *RecordReader) Value() BnCode {
+	return r.value
+}
+
+// Offset returns the position of the first byte of the current record in the stream.
+func (r *RecordReader) Offset() int64 {
+	return r.start
+}
+
+// Skipped returns the number of bytes dropped while resynchronizing after corrupt records.
+func (r *RecordReader) Skipped() int64 {
+	return r.skipped
+}
+
+// Err returns the error that stopped the iteration, nil at the end of the stream.
+// Errors of records cut off by the end of the stream wrap io.ErrUnexpectedEOF.
+func (r *RecordReader) Err() error {
+	return r.err
+}
+
+// RecordWriter appends bencoded records to a stream, in the format read by RecordReader.
+type RecordWriter struct {
+	writer io.Writer
+	buf    []byte
+}
+
+// NewRecordWriter creates a writer of records to the stream.
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return &RecordWriter{writer: w}
+}
+
+// Write encodes the record and writes it with a single call to the underlying writer,
+// so concurrent appends to a file opened with O_APPEND do not interleave.
+func (w *RecordWriter) Write(node BnCode) error {
+	var err error
+	if w.buf, err = AppendEncode(w.buf[:0], node); err != nil {
+		return err
+	}
+	_, err = w.writer.Write(w.buf)
+	return err
+}
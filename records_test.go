@@ -0,0 +1,163 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func record(i int) BnCode {
+	return BnCode{State: BnDict, Value: map[string]BnCode{"n": {State: BnInt, Value: i}}}
+}
+
+func TestRecordWriter_Write(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRecordWriter(&buf)
+	for i := 0; i < 3; i++ {
+		if err := w.Write(record(i)); err != nil {
+			t.Fatalf("RecordWriter.Write() error = %v", err)
+		}
+	}
+	if err := w.Write(BnCode{State: BnList, Value: "x"}); err == nil {
+		t.Errorf("RecordWriter.Write() accepted an invalid node")
+	}
+	if got, want := buf.String(), "d1:ni0eed1:ni1eed1:ni2ee"; got != want {
+		t.Errorf("RecordWriter.Write() = %s, want %s", got, want)
+	}
+}
+
+func TestRecordReader(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		resync      bool
+		wantValues  []int
+		wantOffsets []int64
+		wantSkipped int64
+		wantErr     bool
+		// wantTruncated expects the error to wrap io.ErrUnexpectedEOF
+		wantTruncated bool
+	}{
+		{
+			name:        "Valid records",
+			data:        "d1:ni0eed1:ni1eed1:ni2ee",
+			wantValues:  []int{0, 1, 2},
+			wantOffsets: []int64{0, 8, 16},
+		},
+		{
+			name:    "Empty stream",
+			data:    "",
+			wantErr: false,
+		},
+		{
+			name:    "Unknown leading byte",
+			data:    "xd1:ni0ee",
+			wantErr: true,
+		},
+		{
+			name:        "Corrupt record",
+			data:        "d1:ni0eed1:ni01eed1:ni2ee",
+			wantValues:  []int{0},
+			wantOffsets: []int64{0},
+			wantErr:     true,
+		},
+		{
+			name:          "Corrupt records with resync",
+			data:          "xxd1:ni0eed1:ni01eed1:ni2eed1:n",
+			resync:        true,
+			wantValues:    []int{0, 2},
+			wantOffsets:   []int64{2, 19},
+			wantSkipped:   2 + 9 + 4,
+			wantErr:       true,
+			wantTruncated: true,
+		},
+		{
+			name:          "Truncated record",
+			data:          "d1:ni0eed1:n",
+			wantValues:    []int{0},
+			wantOffsets:   []int64{0},
+			wantErr:       true,
+			wantTruncated: true,
+		},
+		{
+			name:        "Corrupt length prefix with resync",
+			data:        "d99:ad1:ni0ee",
+			resync:      true,
+			wantValues:  []int{0},
+			wantOffsets: []int64{5},
+			wantSkipped: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRecordReader(strings.NewReader(tt.data))
+			if tt.resync {
+				r.Resync()
+			}
+			var values []int
+			var offsets []int64
+			for r.Next() {
+				v := r.Value()
+				dict, _ := v.GetDict()
+				n, _ := As[int](dict["n"])
+				values = append(values, n)
+				offsets = append(offsets, r.Offset())
+			}
+			if (r.Err() != nil) != tt.wantErr {
+				t.Errorf("RecordReader.Err() = %v, wantErr %v", r.Err(), tt.wantErr)
+			}
+			if errors.Is(r.Err(), io.ErrUnexpectedEOF) != tt.wantTruncated {
+				t.Errorf("RecordReader.Err() = %v, wantTruncated %v", r.Err(), tt.wantTruncated)
+			}
+			if len(values) != len(tt.wantValues) || len(offsets) != len(tt.wantOffsets) {
+				t.Fatalf("RecordReader returned %v at %v, want %v at %v", values, offsets, tt.wantValues, tt.wantOffsets)
+			}
+			for i := range values {
+				if values[i] != tt.wantValues[i] || offsets[i] != tt.wantOffsets[i] {
+					t.Errorf("RecordReader returned %v at %v, want %v at %v", values, offsets, tt.wantValues, tt.wantOffsets)
+				}
+			}
+			if r.Skipped() != tt.wantSkipped {
+				t.Errorf("RecordReader.Skipped() = %d, want %d", r.Skipped(), tt.wantSkipped)
+			}
+			if r.Next() {
+				t.Errorf("RecordReader.Next() = true after the end")
+			}
+		})
+	}
+}
+
+func Test_pushbackReader_unread(t *testing.T) {
+	p := &pushbackReader{reader: bufio.NewReader(strings.NewReader("cdef"))}
+	readN := func(n int) string {
+		var rc []byte
+		for i := 0; i < n; i++ {
+			b, err := p.ReadByte()
+			if err != nil {
+				t.Fatalf("pushbackReader.ReadByte() error = %v", err)
+			}
+			rc = append(rc, b)
+		}
+		return string(rc)
+	}
+
+	// the stream is exhausted, the buffer is refilled
+	readN(2)
+	p.unread([]byte("abcd"))
+	if got := readN(3); got != "abc" {
+		t.Errorf("pushbackReader read %q, want %q", got, "abc")
+	}
+	// moves the read index back without growing the buffer
+	p.unread([]byte("bc"))
+	if got, want := len(p.pending), 4; got != want {
+		t.Errorf("pushbackReader buffer is %d bytes long, want %d", got, want)
+	}
+	// does not fit in front of the read index
+	p.unread([]byte("xya"))
+	if got := readN(8); got != "xyabcdef" {
+		t.Errorf("pushbackReader read %q, want %q", got, "xyabcdef")
+	}
+}
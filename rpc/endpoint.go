@@ -0,0 +1,405 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"bencode"
+	"bencode/framing"
+	"bencode/krpc"
+)
+
+const (
+	// DefaultTimeout is how long a query waits for the response before it is sent again
+	DefaultTimeout = 2 * time.Second
+	// DefaultRetries is how many times a query is sent again after timing out
+	DefaultRetries = 2
+	// MaxPacketSize is the largest message sent or received over UDP
+	MaxPacketSize = 65507
+	// DefaultMaxHandlers is how many queries are handled at the same time unless set otherwise
+	DefaultMaxHandlers = 64
+)
+
+var (
+	// ErrTimeout is returned by Call when no response arrived after all the retries
+	ErrTimeout = errors.New("Query timed out")
+	// ErrClosed is returned by Call and Serve once the endpoint is closed
+	ErrClosed = errors.New("Endpoint is closed")
+)
+
+// Handler answers a query with its return value. Returning a *krpc.Error sends back its code and message,
+// any other error or a panic is sent back as krpc.ErrorServer.
+//
+// Queries are sent again when the response is lost, so handlers could run more than once for a single call.
+type Handler func(from net.Addr, args bencode.BnCode) (bencode.BnCode, error)
+
+// Mux maps methods to their handlers.
+// The zero value is ready to use and is safe for concurrent use.
+type Mux struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// Handle registers the handler of the method, replacing the previous one. A nil handler unregisters the method.
+func (m *Mux) Handle(method string, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h == nil {
+		delete(m.handlers, method)
+		return
+	}
+	if m.handlers == nil {
+		m.handlers = make(map[string]Handler)
+	}
+	m.handlers[method] = h
+}
+
+func (m *Mux) handler(method string) Handler {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.handlers[method]
+}
+
+// transport sends and receives whole messages.
+type transport interface {
+	send(node bencode.BnCode, addr net.Addr) error
+	// receive returns the next message, errors are fatal
+	receive() (bencode.BnCode, net.Addr, error)
+	close() error
+	// connectionless tells whether messages come from many peers, told apart by their address
+	connectionless() bool
+}
+
+type packetTransport struct {
+	conn net.PacketConn
+	buf  []byte
+}
+
+func (p *packetTransport) send(node bencode.BnCode, addr net.Addr) error {
+	data, err := bencode.Encode(node)
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxPacketSize {
+		return fmt.Errorf("Encoded message is %d bytes long, at most %d allowed", len(data), MaxPacketSize)
+	}
+	_, err = p.conn.WriteTo(data, addr)
+	return err
+}
+
+// receive drops the datagrams that do not hold exactly one node.
+func (p *packetTransport) receive() (bencode.BnCode, net.Addr, error) {
+	for {
+		n, addr, err := p.conn.ReadFrom(p.buf)
+		if err != nil {
+			return bencode.BnCode{}, nil, err
+		}
+		node, used, err := bencode.DecodeBytes(p.buf[:n])
+		if err == nil && used == n {
+			return node, addr, nil
+		}
+	}
+}
+
+func (p *packetTransport) close() error {
+	return p.conn.Close()
+}
+
+func (p *packetTransport) connectionless() bool {
+	return true
+}
+
+type streamTransport struct {
+	conn *framing.Conn
+}
+
+func (s *streamTransport) send(node bencode.BnCode, _ net.Addr) error {
+	return s.conn.WriteMessage(node)
+}
+
+func (s *streamTransport) receive() (bencode.BnCode, net.Addr, error) {
+	node, err := s.conn.ReadMessage()
+	return node, s.conn.Conn().RemoteAddr(), err
+}
+
+func (s *streamTransport) close() error {
+	return s.conn.Close()
+}
+
+func (s *streamTransport) connectionless() bool {
+	return false
+}
+
+// call is a query waiting for its response.
+type call struct {
+	addr  net.Addr
+	reply chan message
+}
+
+// Endpoint sends queries and answers the incoming ones on a single connection.
+// Serve has to run for the responses to be received, Call is safe for concurrent use.
+// Use the option methods before the first call.
+type Endpoint struct {
+	transport transport
+	mux       *Mux
+	timeout   time.Duration
+	retries   int
+	tids      krpc.TransactionIDs
+	// handlers holds a token for every query being handled
+	handlers chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]*call
+	closed  bool
+	done    chan struct{}
+}
+
+func newEndpoint(t transport, mux *Mux) *Endpoint {
+	return &Endpoint{
+		transport: t,
+		mux:       mux,
+		timeout:   DefaultTimeout,
+		retries:   DefaultRetries,
+		handlers:  make(chan struct{}, DefaultMaxHandlers),
+		pending:   make(map[string]*call),
+		done:      make(chan struct{}),
+	}
+}
+
+// NewPacketEndpoint creates an endpoint that exchanges datagrams, usually over UDP.
+// Incoming queries are dispatched to the mux, which could be nil for endpoints that only send queries.
+func NewPacketEndpoint(conn net.PacketConn, mux *Mux) *Endpoint {
+	return newEndpoint(&packetTransport{conn: conn, buf: make([]byte, MaxPacketSize)}, mux)
+}
+
+// NewStreamEndpoint creates an endpoint that exchanges framed messages on a connection, usually over TCP.
+// Incoming queries are dispatched to the mux, which could be nil for endpoints that only send queries.
+func NewStreamEndpoint(conn net.Conn, mux *Mux) *Endpoint {
+	return newEndpoint(&streamTransport{conn: framing.NewConn(conn)}, mux)
+}
+
+// Timeout sets how long a query waits for the response before it is sent again, non-positive values restore the default.
+func (e *Endpoint) Timeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultTimeout
+	}
+	e.timeout = d
+}
+
+// Retries sets how many times a query is sent again after timing out, negative values restore the default.
+func (e *Endpoint) Retries(n int) {
+	if n < 0 {
+		n = DefaultRetries
+	}
+	e.retries = n
+}
+
+// MaxHandlers limits how many queries are handled at the same time, non-positive values restore the default.
+// Queries received while all the handlers are busy are dropped, the callers send them again after their timeout.
+func (e *Endpoint) MaxHandlers(n int) {
+	if n <= 0 {
+		n = DefaultMaxHandlers
+	}
+	e.handlers = make(chan struct{}, n)
+}
+
+// Call sends the query to the address and waits for the response. The address is ignored by stream endpoints.
+//
+// The query is sent again with the same transaction id each time the timeout expires, up to the number of retries.
+// Returns the return value of the response, a *krpc.Error if the remote side replied with an error,
+// ErrTimeout if no response arrived, ErrClosed if the endpoint was closed or the error of the context.
+func (e *Endpoint) Call(ctx context.Context, addr net.Addr, method string, args bencode.BnCode) (bencode.BnCode, error) {
+	t, c, err := e.register(addr)
+	if err != nil {
+		return bencode.BnCode{}, err
+	}
+	defer e.unregister(t)
+
+	query := encodeQuery(t, method, args)
+	for attempt := 0; attempt <= e.retries; attempt++ {
+		if err = e.transport.send(query, addr); err != nil {
+			return bencode.BnCode{}, err
+		}
+
+		timer := time.NewTimer(e.timeout)
+		select {
+		case m := <-c.reply:
+			timer.Stop()
+			if m.err != nil {
+				return bencode.BnCode{}, m.err
+			}
+			return m.body, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return bencode.BnCode{}, ctx.Err()
+		case <-e.done:
+			timer.Stop()
+			return bencode.BnCode{}, ErrClosed
+		case <-timer.C:
+		}
+	}
+	return bencode.BnCode{}, ErrTimeout
+}
+
+// register reserves a transaction id that is not used by another pending query.
+func (e *Endpoint) register(addr net.Addr) (string, *call, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return "", nil, ErrClosed
+	}
+	for i := 0; i <= 0xffff; i++ {
+		t := e.tids.Next()
+		if _, ok := e.pending[t]; !ok {
+			c := &call{addr: addr, reply: make(chan message, 1)}
+			e.pending[t] = c
+			return t, c, nil
+		}
+	}
+	return "", nil, fmt.Errorf("All transaction ids are in use")
+}
+
+func (e *Endpoint) unregister(t string) {
+	e.mu.Lock()
+	delete(e.pending, t)
+	e.mu.Unlock()
+}
+
+// Serve receives messages until the connection fails or the endpoint is closed, answering the queries
+// and handing the responses to the pending calls. Each query is handled in its own goroutine,
+// up to the limit set by MaxHandlers.
+//
+// Returns ErrClosed after Close, the error of the connection otherwise. Either way the pending calls fail.
+func (e *Endpoint) Serve() error {
+	for {
+		node, addr, err := e.transport.receive()
+		if err != nil {
+			e.mu.Lock()
+			closed := e.closed
+			e.mu.Unlock()
+			if closed {
+				return ErrClosed
+			}
+			e.Close()
+			return err
+		}
+
+		m, err := parseMessage(node)
+		if err != nil {
+			if m.y == krpc.TypeQuery && e.acquire() {
+				reply := encodeError(m.t, krpc.ErrorProtocol, err.Error())
+				go func() {
+					defer e.release()
+					e.transport.send(reply, addr)
+				}()
+			}
+			continue
+		}
+		if m.y == krpc.TypeQuery {
+			if e.acquire() {
+				go func() {
+					defer e.release()
+					e.answer(m, addr)
+				}()
+			}
+			continue
+		}
+		e.deliver(m, addr)
+	}
+}
+
+// acquire reserves a handler without waiting, it reports false if all of them are busy.
+func (e *Endpoint) acquire() bool {
+	select {
+	case e.handlers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *Endpoint) release() {
+	<-e.handlers
+}
+
+func (e *Endpoint) answer(m message, addr net.Addr) {
+	h := e.mux.handler(m.method)
+	if h == nil {
+		e.transport.send(encodeError(m.t, krpc.ErrorMethodUnknown, "Method Unknown"), addr)
+		return
+	}
+
+	ret, err := invoke(h, addr, m.body)
+	if err != nil {
+		if ke, ok := err.(*krpc.Error); ok {
+			e.transport.send(encodeError(m.t, ke.Code, ke.Message), addr)
+		} else {
+			e.transport.send(encodeError(m.t, krpc.ErrorServer, err.Error()), addr)
+		}
+		return
+	}
+	if err = e.transport.send(encodeResponse(m.t, ret), addr); err != nil {
+		// the return value could not be encoded, let the caller know instead of timing out
+		e.transport.send(encodeError(m.t, krpc.ErrorServer, err.Error()), addr)
+	}
+}
+
+// invoke runs the handler, turning a panic into an error so a single query can not bring the endpoint down.
+func invoke(h Handler, from net.Addr, args bencode.BnCode) (ret bencode.BnCode, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Handler panicked: %v", r)
+		}
+	}()
+	return h(from, args)
+}
+
+// deliver hands the response to the pending call, provided it comes from the queried address.
+// Stream endpoints have a single peer, whatever address the caller passed.
+func (e *Endpoint) deliver(m message, addr net.Addr) {
+	e.mu.Lock()
+	c, ok := e.pending[m.t]
+	e.mu.Unlock()
+	if !ok {
+		return
+	}
+	if e.transport.connectionless() && (c.addr == nil || c.addr.String() != addr.String()) {
+		return
+	}
+	select {
+	case c.reply <- m:
+	default:
+	}
+}
+
+// Close closes the connection, which makes Serve return and the pending calls fail with ErrClosed.
+func (e *Endpoint) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.done)
+	e.mu.Unlock()
+	return e.transport.close()
+}
+
+// Serve accepts connections on the listener and answers the queries received on them with the mux,
+// until accepting fails. Every connection is served by its own stream endpoint.
+func Serve(ln net.Listener, mux *Mux) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go NewStreamEndpoint(conn, mux).Serve()
+	}
+}
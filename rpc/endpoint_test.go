@@ -0,0 +1,310 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bencode"
+	"bencode/krpc"
+)
+
+func testMux() *Mux {
+	var mux Mux
+	mux.Handle("ping", func(from net.Addr, args bencode.BnCode) (bencode.BnCode, error) {
+		return str("pong"), nil
+	})
+	mux.Handle("echo", func(from net.Addr, args bencode.BnCode) (bencode.BnCode, error) {
+		// answer out of order to exercise the transaction id matching
+		if i, err := args.GetInt(); err == nil {
+			time.Sleep(time.Duration(i%5) * time.Millisecond)
+		}
+		return args, nil
+	})
+	mux.Handle("fail", func(from net.Addr, args bencode.BnCode) (bencode.BnCode, error) {
+		return bencode.BnCode{}, fmt.Errorf("Something broke")
+	})
+	mux.Handle("krpc", func(from net.Addr, args bencode.BnCode) (bencode.BnCode, error) {
+		return bencode.BnCode{}, &krpc.Error{Code: krpc.ErrorProtocol, Message: "Bad token"}
+	})
+	mux.Handle("panic", func(from net.Addr, args bencode.BnCode) (bencode.BnCode, error) {
+		panic("boom")
+	})
+	return &mux
+}
+
+func listenUDP(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// udpPair starts a server endpoint with testMux and returns a client endpoint and the address of the server.
+func udpPair(t *testing.T) (*Endpoint, net.Addr) {
+	server := NewPacketEndpoint(listenUDP(t), testMux())
+	client := NewPacketEndpoint(listenUDP(t), nil)
+	go server.Serve()
+	go client.Serve()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return client, server.transport.(*packetTransport).conn.LocalAddr()
+}
+
+func TestEndpoint_Call(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		want     bencode.BnCode
+		wantCode int
+	}{
+		{
+			name:   "Response",
+			method: "ping",
+			want:   str("pong"),
+		},
+		{
+			name:     "Unknown method",
+			method:   "missing",
+			wantCode: krpc.ErrorMethodUnknown,
+		},
+		{
+			name:     "Handler error",
+			method:   "fail",
+			wantCode: krpc.ErrorServer,
+		},
+		{
+			name:     "Handler panic",
+			method:   "panic",
+			wantCode: krpc.ErrorServer,
+		},
+		{
+			name:     "Handler KRPC error",
+			method:   "krpc",
+			wantCode: krpc.ErrorProtocol,
+		},
+	}
+	client, addr := udpPair(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.Call(context.Background(), addr, tt.method, bencode.BnCode{State: bencode.BnDict, Value: map[string]bencode.BnCode{}})
+			if tt.wantCode != 0 {
+				ke, ok := err.(*krpc.Error)
+				if !ok || ke.Code != tt.wantCode {
+					t.Errorf("Endpoint.Call() error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Endpoint.Call() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Endpoint.Call() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpoint_Call_concurrent(t *testing.T) {
+	client, addr := udpPair(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got, err := client.Call(context.Background(), addr, "echo", bencode.BnCode{State: bencode.BnInt, Value: i})
+			if err != nil {
+				t.Errorf("Endpoint.Call() error = %v", err)
+				return
+			}
+			if n, _ := got.GetInt(); n != i {
+				t.Errorf("Endpoint.Call() = %d, want %d", n, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEndpoint_Call_retries(t *testing.T) {
+	// the server answers only the third copy of every query
+	conn := listenUDP(t)
+	defer conn.Close()
+	var received int32
+	go func() {
+		buf := make([]byte, MaxPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if atomic.AddInt32(&received, 1)%3 != 0 {
+				continue
+			}
+			node, _, _ := bencode.DecodeBytes(buf[:n])
+			m, _ := parseMessage(node)
+			data, _ := bencode.Encode(encodeResponse(m.t, str("late")))
+			conn.WriteTo(data, addr)
+		}
+	}()
+
+	client := NewPacketEndpoint(listenUDP(t), nil)
+	defer client.Close()
+	go client.Serve()
+	client.Timeout(20 * time.Millisecond)
+
+	client.Retries(1)
+	if _, err := client.Call(context.Background(), conn.LocalAddr(), "ping", str("")); err != ErrTimeout {
+		t.Errorf("Endpoint.Call() error = %v, want %v", err, ErrTimeout)
+	}
+	atomic.StoreInt32(&received, 0)
+
+	client.Retries(2)
+	got, err := client.Call(context.Background(), conn.LocalAddr(), "ping", str(""))
+	if err != nil || !reflect.DeepEqual(got, str("late")) {
+		t.Errorf("Endpoint.Call() = %v, %v, want %v", got, err, str("late"))
+	}
+	if n := atomic.LoadInt32(&received); n != 3 {
+		t.Errorf("Server received %d queries, want 3", n)
+	}
+}
+
+func TestEndpoint_Close(t *testing.T) {
+	// nothing answers on the address, so the call is still pending when the endpoint is closed
+	silent := listenUDP(t)
+	defer silent.Close()
+
+	client := NewPacketEndpoint(listenUDP(t), nil)
+	served := make(chan error)
+	go func() { served <- client.Serve() }()
+
+	called := make(chan error)
+	go func() {
+		_, err := client.Call(context.Background(), silent.LocalAddr(), "ping", str(""))
+		called <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	client.Close()
+
+	if err := <-called; err != ErrClosed {
+		t.Errorf("Endpoint.Call() error = %v, want %v", err, ErrClosed)
+	}
+	if err := <-served; err != ErrClosed {
+		t.Errorf("Endpoint.Serve() error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := client.Call(context.Background(), silent.LocalAddr(), "ping", str("")); err != ErrClosed {
+		t.Errorf("Endpoint.Call() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestEndpoint_Call_context(t *testing.T) {
+	silent := listenUDP(t)
+	defer silent.Close()
+	client := NewPacketEndpoint(listenUDP(t), nil)
+	defer client.Close()
+	go client.Serve()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.Call(ctx, silent.LocalAddr(), "ping", str("")); err != context.DeadlineExceeded {
+		t.Errorf("Endpoint.Call() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestEndpoint_MaxHandlers(t *testing.T) {
+	var mux Mux
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	mux.Handle("block", func(from net.Addr, args bencode.BnCode) (bencode.BnCode, error) {
+		started <- struct{}{}
+		<-release
+		return str("done"), nil
+	})
+	server := NewPacketEndpoint(listenUDP(t), &mux)
+	server.MaxHandlers(1)
+	client := NewPacketEndpoint(listenUDP(t), nil)
+	go server.Serve()
+	go client.Serve()
+	defer server.Close()
+	defer client.Close()
+	addr := server.transport.(*packetTransport).conn.LocalAddr()
+
+	first := make(chan error)
+	go func() {
+		_, err := client.Call(context.Background(), addr, "block", str(""))
+		first <- err
+	}()
+	<-started
+
+	// the only handler is busy, so the query is dropped rather than handled
+	impatient := NewPacketEndpoint(listenUDP(t), nil)
+	impatient.Timeout(20 * time.Millisecond)
+	impatient.Retries(0)
+	go impatient.Serve()
+	defer impatient.Close()
+	if _, err := impatient.Call(context.Background(), addr, "block", str("")); err != ErrTimeout {
+		t.Errorf("Endpoint.Call() error = %v, want %v", err, ErrTimeout)
+	}
+	select {
+	case <-started:
+		t.Errorf("Handler ran beyond the limit")
+	default:
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Errorf("Endpoint.Call() error = %v", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go Serve(ln, testMux())
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewStreamEndpoint(conn, nil)
+	defer client.Close()
+	go client.Serve()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got, err := client.Call(context.Background(), nil, "echo", bencode.BnCode{State: bencode.BnInt, Value: i})
+			if err != nil {
+				t.Errorf("Endpoint.Call() error = %v", err)
+				return
+			}
+			if n, _ := got.GetInt(); n != i {
+				t.Errorf("Endpoint.Call() = %d, want %d", n, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the address is ignored by stream endpoints
+	got, err := client.Call(context.Background(), &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1}, "ping", str(""))
+	if err != nil || !reflect.DeepEqual(got, str("pong")) {
+		t.Errorf("Endpoint.Call() = %v, %v, want %v", got, err, str("pong"))
+	}
+
+	_, err = client.Call(context.Background(), nil, "missing", str(""))
+	if ke, ok := err.(*krpc.Error); !ok || ke.Code != krpc.ErrorMethodUnknown {
+		t.Errorf("Endpoint.Call() error = %v, want code %d", err, krpc.ErrorMethodUnknown)
+	}
+}
@@ -0,0 +1,133 @@
+// Package rpc implements a request/response protocol on top of bencode, modelled on KRPC (BEP 5).
+//
+// Queries carry a method name and arbitrary arguments, responses carry an arbitrary return value
+// and failures are sent back in the KRPC "e" list format, so the messages look like
+//
+//	d1:ad3:key5:valuee1:q4:echo1:t2:aa1:y1:qe
+//	d1:rd3:key5:valuee1:t2:aa1:y1:re
+//	d1:eli204e14:Method Unknowne1:t2:aa1:y1:ee
+//
+// Messages are exchanged as datagrams over UDP or as length-prefixed frames over TCP, see the framing package.
+package rpc
+
+import (
+	"fmt"
+
+	"bencode"
+	"bencode/krpc"
+)
+
+// message is a decoded query, response or error.
+type message struct {
+	t      string
+	y      string
+	method string
+	// body holds the arguments of queries and the return value of responses
+	body bencode.BnCode
+	err  *krpc.Error
+}
+
+func str(s string) bencode.BnCode {
+	return bencode.BnCode{State: bencode.BnString, Value: s}
+}
+
+func envelope(t, y string) map[string]bencode.BnCode {
+	return map[string]bencode.BnCode{"t": str(t), "y": str(y)}
+}
+
+func encodeQuery(t, method string, args bencode.BnCode) bencode.BnCode {
+	rc := envelope(t, krpc.TypeQuery)
+	rc["q"] = str(method)
+	rc["a"] = args
+	return bencode.BnCode{State: bencode.BnDict, Value: rc}
+}
+
+func encodeResponse(t string, ret bencode.BnCode) bencode.BnCode {
+	rc := envelope(t, krpc.TypeResponse)
+	rc["r"] = ret
+	return bencode.BnCode{State: bencode.BnDict, Value: rc}
+}
+
+func encodeError(t string, code int, msg string) bencode.BnCode {
+	rc := envelope(t, krpc.TypeError)
+	rc["e"] = bencode.BnCode{State: bencode.BnList, Value: []bencode.BnCode{
+		{State: bencode.BnInt, Value: code},
+		str(msg),
+	}}
+	return bencode.BnCode{State: bencode.BnDict, Value: rc}
+}
+
+// parseMessage reads the envelope and the body of a message.
+// The transaction id and type are filled in as soon as they are read, so malformed queries could be answered.
+func parseMessage(node bencode.BnCode) (message, error) {
+	var rc message
+	dict, err := node.GetDict()
+	if err != nil {
+		return rc, err
+	}
+	if rc.t, err = requireString(dict, "t"); err != nil {
+		return rc, err
+	}
+	if rc.t == "" || len(rc.t) > krpc.MaxTransactionIDLength {
+		return rc, fmt.Errorf("Transaction id must be 1 to %d bytes long, got %d", krpc.MaxTransactionIDLength, len(rc.t))
+	}
+	if rc.y, err = requireString(dict, "y"); err != nil {
+		return rc, err
+	}
+
+	switch rc.y {
+	case krpc.TypeQuery:
+		if rc.method, err = requireString(dict, "q"); err != nil {
+			return rc, err
+		}
+		if rc.body, err = require(dict, "a"); err != nil {
+			return rc, err
+		}
+	case krpc.TypeResponse:
+		if rc.body, err = require(dict, "r"); err != nil {
+			return rc, err
+		}
+	case krpc.TypeError:
+		val, err := require(dict, "e")
+		if err != nil {
+			return rc, err
+		}
+		list, err := val.GetList()
+		if err != nil {
+			return rc, fmt.Errorf("Key %q: %v", "e", err)
+		}
+		if len(list) != 2 {
+			return rc, fmt.Errorf("Error list must have 2 elements, got %d", len(list))
+		}
+		rc.err = &krpc.Error{TransactionID: rc.t}
+		if rc.err.Code, err = list[0].GetInt(); err != nil {
+			return rc, fmt.Errorf("Key %q: %v", "e", err)
+		}
+		if rc.err.Message, err = list[1].GetString(); err != nil {
+			return rc, fmt.Errorf("Key %q: %v", "e", err)
+		}
+	default:
+		return rc, fmt.Errorf("Unknown message type %q", rc.y)
+	}
+	return rc, nil
+}
+
+func require(dict map[string]bencode.BnCode, key string) (bencode.BnCode, error) {
+	val, ok := dict[key]
+	if !ok {
+		return bencode.BnCode{}, fmt.Errorf("Required key %q is missing", key)
+	}
+	return val, nil
+}
+
+func requireString(dict map[string]bencode.BnCode, key string) (string, error) {
+	val, err := require(dict, key)
+	if err != nil {
+		return "", err
+	}
+	s, err := val.GetString()
+	if err != nil {
+		return "", fmt.Errorf("Key %q: %v", key, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+
+	"bencode"
+	"bencode/krpc"
+)
+
+func decode(t *testing.T, s string) bencode.BnCode {
+	node, _, err := bencode.DecodeBytes([]byte(s))
+	if err != nil {
+		t.Fatalf("DecodeBytes(%q) error = %v", s, err)
+	}
+	return node
+}
+
+func Test_encode(t *testing.T) {
+	args := bencode.BnCode{State: bencode.BnDict, Value: map[string]bencode.BnCode{"key": str("value")}}
+	tests := []struct {
+		name string
+		node bencode.BnCode
+		want string
+	}{
+		{
+			name: "Query",
+			node: encodeQuery("aa", "echo", args),
+			want: "d1:ad3:key5:valuee1:q4:echo1:t2:aa1:y1:qe",
+		},
+		{
+			name: "Response",
+			node: encodeResponse("aa", args),
+			want: "d1:rd3:key5:valuee1:t2:aa1:y1:re",
+		},
+		{
+			name: "Error",
+			node: encodeError("aa", krpc.ErrorMethodUnknown, "Method Unknown"),
+			want: "d1:eli204e14:Method Unknowne1:t2:aa1:y1:ee",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bencode.Encode(tt.node)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Encode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    message
+		wantErr bool
+	}{
+		{
+			name: "Query",
+			data: "d1:ai1e1:q4:echo1:t2:aa1:y1:qe",
+			want: message{t: "aa", y: krpc.TypeQuery, method: "echo", body: bencode.BnCode{State: bencode.BnInt, Value: 1}},
+		},
+		{
+			name: "Response",
+			data: "d1:r2:ok1:t2:aa1:y1:re",
+			want: message{t: "aa", y: krpc.TypeResponse, body: str("ok")},
+		},
+		{
+			name: "Error",
+			data: "d1:eli201e4:oopse1:t2:aa1:y1:ee",
+			want: message{t: "aa", y: krpc.TypeError, err: &krpc.Error{TransactionID: "aa", Code: krpc.ErrorGeneric, Message: "oops"}},
+		},
+		{
+			name:    "Query without arguments",
+			data:    "d1:q4:echo1:t2:aa1:y1:qe",
+			want:    message{t: "aa", y: krpc.TypeQuery, method: "echo"},
+			wantErr: true,
+		},
+		{
+			name:    "Short error list",
+			data:    "d1:eli201ee1:t2:aa1:y1:ee",
+			want:    message{t: "aa", y: krpc.TypeError},
+			wantErr: true,
+		},
+		{
+			name:    "Missing transaction id",
+			data:    "d1:r2:ok1:y1:re",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown type",
+			data:    "d1:t2:aa1:y1:xe",
+			want:    message{t: "aa", y: "x"},
+			wantErr: true,
+		},
+		{
+			name:    "Not a dictionary",
+			data:    "l1:ae",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMessage(decode(t, tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMessage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package bencode
+
+import (
+	"bytes"
+)
+
+// Scanner reads the nodes of a byte slice one at a time in a single pass, without building a tree or an index.
+// It applies the same validation as Parse and is the decoder behind the code generated by bencodegen.
+//
+// Lists and dictionaries are entered with List and Dict, their children are read while More reports true
+// and they are left with End. Every value of a dictionary has to be preceded by its Key.
+// Strings are returned as sub slices of the data, which must not be modified while they are in use.
+type Scanner struct {
+	p     indexer
+	pos   int
+	stack []scanFrame
+}
+
+// scanFrame is a list or dictionary the scanner is in.
+type scanFrame struct {
+	start int
+	dict  bool
+	// key is the last key of the dictionary, nil before the first one
+	key []byte
+	// value tells that the key was read and its value comes next
+	value bool
+}
+
+// NewScanner creates a scanner positioned at the beginning of data.
+func NewScanner(data []byte) *Scanner {
+	return &Scanner{p: indexer{data: data}}
+}
+
+// Offset returns the position of the next byte to read.
+func (s *Scanner) Offset() int {
+	return s.pos
+}
+
+// begin checks that a value can be read at the current position and returns its first byte.
+func (s *Scanner) begin() (byte, error) {
+	if n := len(s.stack); n > 0 && s.stack[n-1].dict {
+		if !s.stack[n-1].value {
+			return 0, s.p.errorf(s.pos, "Expected a dictionary key")
+		}
+		s.stack[n-1].value = false
+	}
+	if s.pos >= len(s.p.data) {
+		return 0, s.p.errorf(s.pos, "Unexpected end of data")
+	}
+	return s.p.data[s.pos], nil
+}
+
+// Int reads an int.
+func (s *Scanner) Int() (int, error) {
+	c, err := s.begin()
+	if err != nil {
+		return 0, err
+	}
+	if c != 'i' {
+		return 0, s.p.errorf(s.pos, "Given Value is not an int")
+	}
+	end, err := s.p.intEnd(s.pos)
+	if err != nil {
+		return 0, err
+	}
+	val, _ := parseDigits(s.p.data[s.pos+1 : end-1])
+	s.pos = end
+	return val, nil
+}
+
+// Bytes reads a string and returns its content as a sub slice of the data.
+func (s *Scanner) Bytes() ([]byte, error) {
+	c, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	if c < '0' || c > '9' {
+		return nil, s.p.errorf(s.pos, "Given Value is not a string")
+	}
+	start, end, err := s.p.stringEnd(s.pos)
+	if err != nil {
+		return nil, err
+	}
+	s.pos = end
+	return s.p.data[start:end:end], nil
+}
+
+// Str reads a string.
+func (s *Scanner) Str() (string, error) {
+	b, err := s.Bytes()
+	return string(b), err
+}
+
+// Raw validates the next node, whatever its type, and returns its encoded bytes.
+// It is used to skip nodes or hand them over to an Unmarshaler.
+func (s *Scanner) Raw() ([]byte, error) {
+	if _, err := s.begin(); err != nil {
+		return nil, err
+	}
+	start := s.pos
+	end, err := s.p.node(start)
+	// the index is only built to validate the node, its memory is kept for the next one
+	s.p.index = s.p.index[:0]
+	if err != nil {
+		return nil, err
+	}
+	s.pos = end
+	return s.p.data[start:end:end], nil
+}
+
+// List enters a list.
+func (s *Scanner) List() error {
+	return s.enter('l', false, "Given Value is not a list")
+}
+
+// Dict enters a dictionary.
+func (s *Scanner) Dict() error {
+	return s.enter('d', true, "Given Value is not a dictionary")
+}
+
+func (s *Scanner) enter(delim byte, dict bool, mismatch string) error {
+	c, err := s.begin()
+	if err != nil {
+		return err
+	}
+	if c != delim {
+		return s.p.errorf(s.pos, "%s", mismatch)
+	}
+	s.stack = append(s.stack, scanFrame{start: s.pos, dict: dict})
+	s.pos++
+	return nil
+}
+
+// More reports whether the list or dictionary the scanner is in has more children.
+// It reports true at the end of the data, so reading the child reports the error.
+func (s *Scanner) More() bool {
+	if len(s.stack) == 0 {
+		return false
+	}
+	return s.pos >= len(s.p.data) || s.p.data[s.pos] != 'e'
+}
+
+// Key reads the next key of the dictionary the scanner is in, keys have to be sorted.
+func (s *Scanner) Key() ([]byte, error) {
+	n := len(s.stack)
+	if n == 0 || !s.stack[n-1].dict || s.stack[n-1].value {
+		return nil, s.p.errorf(s.pos, "Unexpected dictionary key")
+	}
+	if s.pos >= len(s.p.data) {
+		return nil, s.p.errorf(s.stack[n-1].start, "Unterminated dictionary")
+	}
+	if c := s.p.data[s.pos]; c < '0' || c > '9' {
+		return nil, s.p.errorf(s.pos, "Dictionary key must be a string")
+	}
+	start, end, err := s.p.stringEnd(s.pos)
+	if err != nil {
+		return nil, err
+	}
+	key := s.p.data[start:end:end]
+	if prev := s.stack[n-1].key; prev != nil && bytes.Compare(prev, key) > 0 {
+		return nil, s.p.errorf(s.pos, "Dictionary keys are not in lexicographical order")
+	}
+	s.stack[n-1].key = key
+	s.stack[n-1].value = true
+	s.pos = end
+	return key, nil
+}
+
+// End leaves the list or dictionary the scanner is in, all of its children have to be read.
+func (s *Scanner) End() error {
+	n := len(s.stack)
+	if n == 0 {
+		return s.p.errorf(s.pos, "Not in a list or dictionary")
+	}
+	frame := s.stack[n-1]
+	switch {
+	case frame.value:
+		return s.p.errorf(s.pos, "Expected the value of the key %q", frame.key)
+	case s.pos >= len(s.p.data) && frame.dict:
+		return s.p.errorf(frame.start, "Unterminated dictionary")
+	case s.pos >= len(s.p.data):
+		return s.p.errorf(frame.start, "Unterminated list")
+	case s.p.data[s.pos] != 'e':
+		return s.p.errorf(s.pos, "Expected the end of the list or dictionary")
+	}
+	s.stack = s.stack[:n-1]
+	s.pos++
+	return nil
+}
+
+// Done checks that the root node was read whole and nothing follows it.
+func (s *Scanner) Done() error {
+	if n := len(s.stack); n != 0 {
+		return s.p.errorf(s.stack[n-1].start, "List or dictionary was not left with End")
+	}
+	if s.pos != len(s.p.data) {
+		return s.p.errorf(s.pos, "Unexpected %d trailing bytes after the root node", len(s.p.data)-s.pos)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	s := NewScanner([]byte("d1:ai-7e1:bl3:abcli1eee1:cd1:xi1eee"))
+	if err := s.Dict(); err != nil {
+		t.Fatalf("Scanner.Dict() error = %v", err)
+	}
+	var keys []string
+	var got []interface{}
+	for s.More() {
+		key, err := s.Key()
+		if err != nil {
+			t.Fatalf("Scanner.Key() error = %v", err)
+		}
+		keys = append(keys, string(key))
+		switch string(key) {
+		case "a":
+			n, err := s.Int()
+			if err != nil {
+				t.Fatalf("Scanner.Int() error = %v", err)
+			}
+			got = append(got, n)
+		case "b":
+			if err = s.List(); err != nil {
+				t.Fatalf("Scanner.List() error = %v", err)
+			}
+			str, err := s.Str()
+			if err != nil {
+				t.Fatalf("Scanner.Str() error = %v", err)
+			}
+			raw, err := s.Raw()
+			if err != nil {
+				t.Fatalf("Scanner.Raw() error = %v", err)
+			}
+			got = append(got, str, string(raw))
+			if err = s.End(); err != nil {
+				t.Fatalf("Scanner.End() error = %v", err)
+			}
+		default:
+			raw, err := s.Raw()
+			if err != nil {
+				t.Fatalf("Scanner.Raw() error = %v", err)
+			}
+			got = append(got, string(raw))
+		}
+	}
+	if err := s.End(); err != nil {
+		t.Fatalf("Scanner.End() error = %v", err)
+	}
+	if err := s.Done(); err != nil {
+		t.Errorf("Scanner.Done() error = %v", err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Scanner.Key() = %v, want %v", keys, want)
+	}
+	if want := []interface{}{-7, "abc", "li1ee", "d1:xi1ee"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Scanner read %v, want %v", got, want)
+	}
+}
+
+func TestScanner_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		scan func(s *Scanner) error
+		want string
+	}{
+		{
+			name: "Type mismatch",
+			data: "3:abc",
+			scan: func(s *Scanner) error { _, err := s.Int(); return err },
+			want: "Offset 0: Given Value is not an int",
+		},
+		{
+			name: "Value without key",
+			data: "di1ee",
+			scan: func(s *Scanner) error {
+				s.Dict()
+				_, err := s.Int()
+				return err
+			},
+			want: "Offset 1: Expected a dictionary key",
+		},
+		{
+			name: "Unsorted keys",
+			data: "d1:bi1e1:ai2ee",
+			scan: func(s *Scanner) error {
+				s.Dict()
+				s.Key()
+				s.Int()
+				_, err := s.Key()
+				return err
+			},
+			want: "Offset 7: Dictionary keys are not in lexicographical order",
+		},
+		{
+			name: "Missing value",
+			data: "d1:ae",
+			scan: func(s *Scanner) error {
+				s.Dict()
+				s.Key()
+				return s.End()
+			},
+			want: `Offset 4: Expected the value of the key "a"`,
+		},
+		{
+			name: "Unterminated list",
+			data: "li1e",
+			scan: func(s *Scanner) error {
+				s.List()
+				s.Int()
+				return s.End()
+			},
+			want: "Offset 0: Unterminated list",
+		},
+		{
+			name: "Invalid skipped node",
+			data: "li01ee",
+			scan: func(s *Scanner) error { _, err := s.Raw(); return err },
+			want: "Offset 1: Leading zeros are not allowed",
+		},
+		{
+			name: "Trailing bytes",
+			data: "i1ei2e",
+			scan: func(s *Scanner) error {
+				s.Int()
+				return s.Done()
+			},
+			want: "Offset 3: Unexpected 3 trailing bytes after the root node",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.scan(NewScanner([]byte(tt.data))); err == nil || err.Error() != tt.want {
+				t.Errorf("Scanner error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
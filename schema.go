@@ -0,0 +1,335 @@
+package bencode
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Range is an inclusive range of integers.
+type Range struct {
+	Min, Max int
+}
+
+// String formats the range in the interval notation, an unbounded maximum is written as inf.
+func (r Range) String() string {
+	if r.Max == math.MaxInt {
+		return fmt.Sprintf("[%d, inf)", r.Min)
+	}
+	return fmt.Sprintf("[%d, %d]", r.Min, r.Max)
+}
+
+func (r Range) contains(i int) bool {
+	return i >= r.Min && i <= r.Max
+}
+
+// Schema describes the allowed shape of a node.
+//
+// Constraints that do not apply to the type of the node are ignored, unset constraints accept everything,
+// so the zero Schema accepts any node.
+type Schema struct {
+	// Types lists the allowed States of the node, empty accepts every type
+	Types []State
+	// OneOf lists alternative schemas, the node has to match at least one of them. Types is ignored if it is set.
+	// If none matches, the violations of the first alternative that accepts the type of the node are reported.
+	OneOf []Schema
+	// Range limits the value of ints
+	Range *Range
+	// Len limits the length of strings and the number of elements of lists
+	Len *Range
+	// Pattern has to match the content of strings
+	Pattern *regexp.Regexp
+	// Elem is the schema of every element of lists
+	Elem *Schema
+	// Keys are the schemas of the known keys of dictionaries
+	Keys map[string]Schema
+	// Required are the keys dictionaries must have
+	Required []string
+	// AllowExtra accepts dictionary keys that are not listed in Keys
+	AllowExtra bool
+	// Check validates what the other constraints can not express, it is called with nodes of the accepted types
+	Check func(node BnCode) error
+}
+
+// ValidationError is a single violation of a Schema.
+type ValidationError struct {
+	Path    Path
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	return e.Path.String() + ": " + e.Message
+}
+
+// Validate checks the node against the schema and returns all the violations found, or nil if there are none.
+func Validate(node BnCode, schema Schema) []ValidationError {
+	var v validator
+	v.validate(Path{}, node, &schema)
+	return v.errors
+}
+
+// accepts tells whether nodes of the state satisfy Types.
+func (s *Schema) accepts(state State) bool {
+	if len(s.Types) == 0 {
+		return true
+	}
+	for _, t := range s.Types {
+		if t == state {
+			return true
+		}
+	}
+	return false
+}
+
+// typeNames joins the names of the states with or, an empty list is any.
+func typeNames(states []State) string {
+	if len(states) == 0 {
+		return "any"
+	}
+	names := make([]string, len(states))
+	for i, state := range states {
+		names[i] = state.String()
+	}
+	return strings.Join(names, " or ")
+}
+
+type validator struct {
+	errors []ValidationError
+}
+
+func (v *validator) errorf(path Path, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(path Path, node BnCode, schema *Schema) {
+	if len(schema.OneOf) > 0 {
+		var closest []ValidationError
+		var types []State
+		for i := range schema.OneOf {
+			var alt validator
+			alt.validate(path, node, &schema.OneOf[i])
+			if len(alt.errors) == 0 {
+				return
+			}
+			if closest == nil && schema.OneOf[i].accepts(node.State) {
+				closest = alt.errors
+			}
+			types = append(types, schema.OneOf[i].Types...)
+		}
+		if closest == nil {
+			v.errorf(path, "Expected %s, got %s", typeNames(types), node.State)
+			return
+		}
+		v.errors = append(v.errors, closest...)
+		return
+	}
+	if !schema.accepts(node.State) {
+		v.errorf(path, "Expected %s, got %s", typeNames(schema.Types), node.State)
+		return
+	}
+	if schema.Check != nil {
+		if err := schema.Check(node); err != nil {
+			v.errorf(path, "%v", err)
+		}
+	}
+
+	switch node.State {
+	case BnInt:
+		i, err := node.GetInt()
+		if err != nil {
+			v.errorf(path, "%v", err)
+			return
+		}
+		if schema.Range != nil && !schema.Range.contains(i) {
+			v.errorf(path, "Value %d is out of range %v", i, *schema.Range)
+		}
+	case BnString:
+		s, err := node.GetString()
+		if err != nil {
+			v.errorf(path, "%v", err)
+			return
+		}
+		if schema.Len != nil && !schema.Len.contains(len(s)) {
+			v.errorf(path, "Length %d is out of range %v", len(s), *schema.Len)
+		}
+		if schema.Pattern != nil && !schema.Pattern.MatchString(s) {
+			v.errorf(path, "Value %q does not match %s", s, schema.Pattern)
+		}
+	case BnList:
+		list, err := node.GetList()
+		if err != nil {
+			v.errorf(path, "%v", err)
+			return
+		}
+		if schema.Len != nil && !schema.Len.contains(len(list)) {
+			v.errorf(path, "Length %d is out of range %v", len(list), *schema.Len)
+		}
+		if schema.Elem != nil {
+			for i, elem := range list {
+				v.validate(path.Index(i), elem, schema.Elem)
+			}
+		}
+	case BnDict:
+		dict, err := node.GetDict()
+		if err != nil {
+			v.errorf(path, "%v", err)
+			return
+		}
+		for _, k := range schema.Required {
+			if _, ok := dict[k]; !ok {
+				v.errorf(path.Key(k), "Required key is missing")
+			}
+		}
+		for _, k := range sortedKeys(dict) {
+			keySchema, ok := schema.Keys[k]
+			switch {
+			case ok:
+				v.validate(path.Key(k), dict[k], &keySchema)
+			case !schema.AllowExtra:
+				v.errorf(path.Key(k), "Unknown key")
+			}
+		}
+	default:
+		v.errorf(path, "Unknown type %v", node.State)
+	}
+}
+
+var (
+	nonNegative = &Range{Min: 0, Max: math.MaxInt}
+	sha1Len     = &Range{Min: 20, Max: 20}
+	stringList  = &Schema{Types: []State{BnList}, Elem: &Schema{Types: []State{BnString}}}
+)
+
+// checkInfo requires exactly one of the length of single file torrents and the files of multi file ones.
+func checkInfo(node BnCode) error {
+	dict, err := node.GetDict()
+	if err != nil {
+		return err
+	}
+	_, length := dict["length"]
+	_, files := dict["files"]
+	if length == files {
+		return fmt.Errorf("Exactly one of length and files is required")
+	}
+	return nil
+}
+
+// checkPieces requires the pieces to be made of whole SHA-1 hashes.
+func checkPieces(node BnCode) error {
+	s, err := node.GetString()
+	if err != nil {
+		return err
+	}
+	if len(s)%20 != 0 {
+		return fmt.Errorf("Length %d is not a multiple of 20", len(s))
+	}
+	return nil
+}
+
+// MetainfoSchema describes the metainfo (.torrent) files of BEP 3, with the announce-list of BEP 12,
+// the nodes of BEP 5 and the private flag of BEP 27. Unknown keys are allowed.
+var MetainfoSchema = Schema{
+	Types: []State{BnDict},
+	Keys: map[string]Schema{
+		"announce":      {Types: []State{BnString}, Len: &Range{Min: 1, Max: math.MaxInt}},
+		"announce-list": {Types: []State{BnList}, Elem: stringList},
+		"nodes":         {Types: []State{BnList}, Elem: &Schema{Types: []State{BnList}, Len: &Range{Min: 2, Max: 2}}},
+		"comment":       {Types: []State{BnString}},
+		"created by":    {Types: []State{BnString}},
+		"creation date": {Types: []State{BnInt}, Range: nonNegative},
+		"encoding":      {Types: []State{BnString}},
+		"info": {
+			Types: []State{BnDict},
+			Keys: map[string]Schema{
+				"name":         {Types: []State{BnString}, Len: &Range{Min: 1, Max: math.MaxInt}},
+				"piece length": {Types: []State{BnInt}, Range: &Range{Min: 1, Max: math.MaxInt}},
+				"pieces":       {Types: []State{BnString}, Len: &Range{Min: 20, Max: math.MaxInt}, Check: checkPieces},
+				"length":       {Types: []State{BnInt}, Range: nonNegative},
+				"md5sum":       {Types: []State{BnString}, Len: &Range{Min: 32, Max: 32}},
+				"private":      {Types: []State{BnInt}, Range: &Range{Min: 0, Max: 1}},
+				"files": {
+					Types: []State{BnList},
+					Len:   &Range{Min: 1, Max: math.MaxInt},
+					Elem: &Schema{
+						Types: []State{BnDict},
+						Keys: map[string]Schema{
+							"length": {Types: []State{BnInt}, Range: nonNegative},
+							"path":   {Types: []State{BnList}, Len: &Range{Min: 1, Max: math.MaxInt}, Elem: &Schema{Types: []State{BnString}}},
+							"md5sum": {Types: []State{BnString}, Len: &Range{Min: 32, Max: 32}},
+						},
+						Required:   []string{"length", "path"},
+						AllowExtra: true,
+					},
+				},
+			},
+			Required:   []string{"name", "piece length", "pieces"},
+			AllowExtra: true,
+			Check:      checkInfo,
+		},
+	},
+	Required:   []string{"info"},
+	AllowExtra: true,
+}
+
+// TrackerResponseSchema describes the responses of HTTP trackers of BEP 3, with the compact peers of BEP 23
+// and the IPv6 peers of BEP 7. Unknown keys are allowed.
+var TrackerResponseSchema = Schema{
+	Types: []State{BnDict},
+	Keys: map[string]Schema{
+		"failure reason":  {Types: []State{BnString}},
+		"warning message": {Types: []State{BnString}},
+		"interval":        {Types: []State{BnInt}, Range: nonNegative},
+		"min interval":    {Types: []State{BnInt}, Range: nonNegative},
+		"tracker id":      {Types: []State{BnString}},
+		"complete":        {Types: []State{BnInt}, Range: nonNegative},
+		"incomplete":      {Types: []State{BnInt}, Range: nonNegative},
+		"peers": {OneOf: []Schema{
+			{Types: []State{BnString}},
+			{Types: []State{BnList}, Elem: &Schema{
+				Types: []State{BnDict},
+				Keys: map[string]Schema{
+					"peer id": {Types: []State{BnString}, Len: sha1Len},
+					"ip":      {Types: []State{BnString}},
+					"port":    {Types: []State{BnInt}, Range: &Range{Min: 0, Max: 65535}},
+				},
+				Required:   []string{"ip", "port"},
+				AllowExtra: true,
+			}},
+		}},
+		"peers6": {Types: []State{BnString}},
+	},
+	AllowExtra: true,
+}
+
+// KRPCSchema describes the DHT messages of BEP 5. Unknown keys are allowed.
+var KRPCSchema = Schema{
+	Types: []State{BnDict},
+	Keys: map[string]Schema{
+		"t": {Types: []State{BnString}, Len: &Range{Min: 1, Max: 16}},
+		"y": {Types: []State{BnString}, Pattern: regexp.MustCompile(`^[qre]$`)},
+		"q": {Types: []State{BnString}, Len: &Range{Min: 1, Max: math.MaxInt}},
+		"v": {Types: []State{BnString}},
+		"a": {
+			Types:      []State{BnDict},
+			Keys:       map[string]Schema{"id": {Types: []State{BnString}, Len: sha1Len}},
+			Required:   []string{"id"},
+			AllowExtra: true,
+		},
+		"r": {
+			Types:      []State{BnDict},
+			Keys:       map[string]Schema{"id": {Types: []State{BnString}, Len: sha1Len}},
+			Required:   []string{"id"},
+			AllowExtra: true,
+		},
+		"e": {
+			Types: []State{BnList},
+			Len:   &Range{Min: 2, Max: 2},
+		},
+	},
+	Required:   []string{"t", "y"},
+	AllowExtra: true,
+}
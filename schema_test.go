@@ -0,0 +1,205 @@
+package bencode
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func decodeString(t *testing.T, s string) BnCode {
+	t.Helper()
+	node, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("Decode(%q) error = %v", s, err)
+	}
+	return node
+}
+
+func errorStrings(errs []ValidationError) []string {
+	var rc []string
+	for _, e := range errs {
+		rc = append(rc, e.Error())
+	}
+	return rc
+}
+
+func TestValidate(t *testing.T) {
+	schema := Schema{
+		Types: []State{BnDict},
+		Keys: map[string]Schema{
+			"n":    {Types: []State{BnInt}, Range: &Range{Min: 0, Max: 10}},
+			"s":    {Types: []State{BnString}, Len: &Range{Min: 1, Max: 3}, Pattern: regexp.MustCompile(`^[a-z]+$`)},
+			"l":    {Types: []State{BnList}, Elem: &Schema{Types: []State{BnInt}}},
+			"any":  {},
+			"both": {OneOf: []Schema{{Types: []State{BnInt}}, {Types: []State{BnString}}}},
+		},
+		Required: []string{"n", "s"},
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{name: "Valid", data: "d3:anyle4:both1:x1:lli1ee1:ni5e1:s2:abe", want: nil},
+		{name: "Missing keys", data: "de", want: []string{"n: Required key is missing", "s: Required key is missing"}},
+		{
+			name: "Every violation",
+			data: "d4:bothle1:lli1e1:xe1:ni11e5:otheri1e1:s4:ABCDe",
+			want: []string{
+				"both: Expected int or string, got list",
+				"l[1]: Expected int, got string",
+				"n: Value 11 is out of range [0, 10]",
+				"other: Unknown key",
+				"s: Length 4 is out of range [1, 3]",
+				`s: Value "ABCD" does not match ^[a-z]+$`,
+			},
+		},
+		{name: "Wrong root type", data: "le", want: []string{"Expected dict, got list"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStrings(Validate(decodeString(t, tt.data), schema)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_Types(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema Schema
+		data   string
+		want   []string
+	}{
+		{name: "Empty schema", schema: Schema{}, data: "le", want: nil},
+		{
+			name:   "Empty nested schema",
+			schema: Schema{Types: []State{BnDict}, Keys: map[string]Schema{"a": {}}},
+			data:   "d1:a3:xyze",
+			want:   nil,
+		},
+		{
+			name:   "Several types",
+			schema: Schema{Types: []State{BnInt, BnString}, Len: &Range{Min: 1, Max: 2}},
+			data:   "3:abc",
+			want:   []string{"Length 3 is out of range [1, 2]"},
+		},
+		{
+			name:   "None of several types",
+			schema: Schema{Types: []State{BnInt, BnString}},
+			data:   "le",
+			want:   []string{"Expected int or string, got list"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStrings(Validate(decodeString(t, tt.data), tt.schema)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_OneOf(t *testing.T) {
+	schema := Schema{OneOf: []Schema{
+		{Types: []State{BnInt}, Range: &Range{Min: 0, Max: 5}},
+		{Types: []State{BnList}, Elem: &Schema{Types: []State{BnString}}},
+	}}
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{name: "First alternative", data: "i3e", want: nil},
+		{name: "Second alternative", data: "l1:ae", want: nil},
+		{name: "Closest int", data: "i9e", want: []string{"Value 9 is out of range [0, 5]"}},
+		{name: "Closest list", data: "l1:ai1ee", want: []string{"[1]: Expected string, got int"}},
+		{name: "No alternative", data: "1:x", want: []string{"Expected int or list, got string"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStrings(Validate(decodeString(t, tt.data), schema)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_BuiltinSchemas(t *testing.T) {
+	pieces := strings.Repeat("x", 20)
+	tests := []struct {
+		name   string
+		schema Schema
+		data   string
+		want   []string
+	}{
+		{
+			name:   "Single file metainfo",
+			schema: MetainfoSchema,
+			data:   "d8:announce3:url4:infod6:lengthi1e4:name1:a12:piece lengthi16384e6:pieces20:" + pieces + "ee",
+			want:   nil,
+		},
+		{
+			name:   "Broken metainfo",
+			schema: MetainfoSchema,
+			data:   "d4:infod5:filesld6:lengthi-1eee4:name0:12:piece lengthi0e7:privatei2eee",
+			want: []string{
+				"info.pieces: Required key is missing",
+				"info.files[0].path: Required key is missing",
+				"info.files[0].length: Value -1 is out of range [0, inf)",
+				"info.name: Length 0 is out of range [1, inf)",
+				"info.piece length: Value 0 is out of range [1, inf)",
+				"info.private: Value 2 is out of range [0, 1]",
+			},
+		},
+		{
+			name:   "Metainfo with both length and files",
+			schema: MetainfoSchema,
+			data:   "d4:infod5:filesld6:lengthi1e4:pathl1:aeee6:lengthi1e4:name1:a12:piece lengthi1e6:pieces21:" + pieces + "xee",
+			want: []string{
+				"info: Exactly one of length and files is required",
+				"info.pieces: Length 21 is not a multiple of 20",
+			},
+		},
+		{
+			name:   "Metainfo without length and files",
+			schema: MetainfoSchema,
+			data:   "d4:infod4:name1:a12:piece lengthi1e6:pieces20:" + pieces + "ee",
+			want:   []string{"info: Exactly one of length and files is required"},
+		},
+		{
+			name:   "Compact tracker response",
+			schema: TrackerResponseSchema,
+			data:   "d8:intervali1800e5:peers6:abcdefe",
+			want:   nil,
+		},
+		{
+			name:   "Tracker response with peer list",
+			schema: TrackerResponseSchema,
+			data:   "d8:intervali1800e5:peersld2:ip1:14:porti1eeee",
+			want:   nil,
+		},
+		{
+			name:   "KRPC query",
+			schema: KRPCSchema,
+			data:   "d1:ad2:id20:" + pieces + "e1:q4:ping1:t2:aa1:y1:qe",
+			want:   nil,
+		},
+		{
+			name:   "Broken KRPC",
+			schema: KRPCSchema,
+			data:   "d1:rd2:id1:xe1:y1:xe",
+			want:   []string{"t: Required key is missing", "r.id: Length 1 is out of range [20, 20]", `y: Value "x" does not match ^[qre]$`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStrings(Validate(decodeString(t, tt.data), tt.schema)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package bencode
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// StreamedString is the Value of a BnString node whose content is not held in memory.
+//
+// Decoder stores it, without a Reader, in place of the strings passed to the StreamStrings callback.
+// Encoder writes a string of Length bytes copied from the Reader.
+type StreamedString struct {
+	Length int
+	Reader io.Reader
+}
+
+// StreamStrings makes the decoder pass the content of strings longer than threshold bytes to fn,
+// instead of reading them into memory. The callback receives the path of the string within the decoded
+// node, its length and a reader limited to the content. Unread content is skipped once fn returns and
+// the node gets a StreamedString value. Errors returned by fn abort decoding.
+//
+// Dictionary keys are never streamed, and streamed strings are not subject to MaxStringLength.
+func (d *Decoder) StreamStrings(threshold int, fn func(path Path, length int, r io.Reader) error) {
+	d.streamThreshold = threshold
+	d.streamFn = fn
+}
+
+// byteReaderAdapter reads through io.ByteReader, for the streams that do not implement io.Reader.
+type byteReaderAdapter struct {
+	r io.ByteReader
+}
+
+func (a byteReaderAdapter) Read(p []byte) (int, error) {
+	for i := range p {
+		b, err := a.r.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = b
+	}
+	return len(p), nil
+}
+
+func (d *Decoder) parseStreamedString(firstChar byte) (BnCode, error) {
+	rc := BnCode{State: BnString}
+	length, err := d.readLength(firstChar)
+	if err != nil {
+		return rc, err
+	}
+	if length <= d.streamThreshold {
+		if d.maxStringLength > 0 && length > d.maxStringLength {
+			return rc, fmt.Errorf("String of %d bytes exceeds the limit of %d bytes", length, d.maxStringLength)
+		}
+		val, err := d.readString(length)
+		if err != nil {
+			return rc, err
+		}
+		rc.Value = val
+		return rc, nil
+	}
+
+	r, ok := d.reader.(io.Reader)
+	if !ok {
+		r = byteReaderAdapter{d.reader}
+	}
+	content := &io.LimitedReader{R: r, N: int64(length)}
+	if err = d.streamFn(d.path, length, content); err != nil {
+		return rc, err
+	}
+	if _, err = io.Copy(ioutil.Discard, content); err != nil {
+		return rc, err
+	}
+	if content.N > 0 {
+		return rc, io.ErrUnexpectedEOF
+	}
+	rc.Value = StreamedString{Length: length}
+	return rc, nil
+}
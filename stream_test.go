@@ -0,0 +1,70 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_StreamStrings(t *testing.T) {
+	pieces := strings.Repeat("p", 100)
+	data := "d4:infod4:name3:abc6:pieces100:" + pieces + "e6:layersl10:0123456789ee"
+
+	for _, reader := range []io.ByteReader{strings.NewReader(data), byteReader{strings.NewReader(data)}} {
+		streamed := make(map[string]string)
+		d := NewDecoder(reader)
+		d.StreamStrings(5, func(path Path, length int, r io.Reader) error {
+			if path.String() == "layers[0]" {
+				// leave the content unread, the decoder skips it
+				return nil
+			}
+			content, err := ioutil.ReadAll(r)
+			if len(content) != length {
+				t.Errorf("Streamed %d bytes of %d", len(content), length)
+			}
+			streamed[path.String()] = string(content)
+			return err
+		})
+		got, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decoder.Decode() error = %v", err)
+		}
+
+		want := BnCode{State: BnDict, Value: map[string]BnCode{
+			"info": {State: BnDict, Value: map[string]BnCode{
+				"name":   {State: BnString, Value: "abc"},
+				"pieces": {State: BnString, Value: StreamedString{Length: 100}},
+			}},
+			"layers": {State: BnList, Value: []BnCode{{State: BnString, Value: StreamedString{Length: 10}}}},
+		}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decoder.Decode() = %v, want %v", got, want)
+		}
+		if !reflect.DeepEqual(streamed, map[string]string{"info.pieces": pieces}) {
+			t.Errorf("Streamed %v", streamed)
+		}
+	}
+}
+
+func TestDecoder_StreamStringsErrors(t *testing.T) {
+	failure := errors.New("failure")
+	d := NewDecoder(strings.NewReader("d1:a10:0123456789e"))
+	d.StreamStrings(0, func(path Path, length int, r io.Reader) error {
+		return failure
+	})
+	if _, err := d.Decode(); err != failure {
+		t.Errorf("Decoder.Decode() error = %v, want %v", err, failure)
+	}
+
+	d = NewDecoder(bytes.NewReader([]byte("10:01234")))
+	d.StreamStrings(0, func(path Path, length int, r io.Reader) error {
+		return nil
+	})
+	if _, err := d.Decode(); err == nil {
+		t.Errorf("Decoder.Decode() accepted a truncated string")
+	}
+}
@@ -0,0 +1,564 @@
+package bencode
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// Unmarshal decodes the bencoded data into the value pointed to by v.
+//
+// It is the reverse of Marshal: ints are stored into integers and booleans, strings into
+// strings, byte slices and byte arrays of the exact length, lists into slices and arrays,
+// dictionaries into maps and structs. Values of the empty interface receive int, string,
+// []interface{} and map[string]interface{}. BnCode values receive the decoded node and types
+// implementing Unmarshaler decode themselves.
+//
+// Like encoding/json, it merges into the existing value: struct fields and map entries of missing keys
+// keep their values, non-nil maps and pointers are reused rather than replaced. Slices and arrays are
+// overwritten. Unknown dictionary keys are ignored, see Decoder.DisallowUnknownFields to reject them.
+//
+// Returns error if data does not hold exactly one valid value or it does not fit into v.
+func Unmarshal(data []byte, v interface{}) error {
+	return unmarshalState{}.unmarshal(data, v)
+}
+
+// DisallowUnknownFields makes DecodeInto return an error when a dictionary holds a key
+// that does not match any field of the destination struct.
+func (d *Decoder) DisallowUnknownFields() {
+	d.disallowUnknownFields = true
+}
+
+// recorder keeps a copy of everything read through it.
+type recorder struct {
+	reader io.ByteReader
+	buf    []byte
+}
+
+func (r *recorder) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, b)
+	}
+	return b, err
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	reader, ok := r.reader.(io.Reader)
+	if !ok {
+		reader = byteReaderAdapter{r.reader}
+	}
+	n, err := reader.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// DecodeInto reads the next node from the stream and stores it into the value pointed to by v,
+// following the rules of Unmarshal and the options of the decoder. Strings are never streamed,
+// with PreserveOrder dictionaries do not have to be sorted and the last of duplicate keys wins.
+//
+// The node is decoded straight into v in a single pass. A part of the node that does not fit into v
+// is skipped and the rest is still decoded, so the decoder is left at the start of the next node
+// and the first such error is returned. Errors of the stream itself stop decoding at once.
+func (d *Decoder) DecodeInto(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Unmarshal target must be a non-nil pointer, got %T", v)
+	}
+	b, err := d.reader.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	streamFn := d.streamFn
+	d.streamFn = nil
+	defer func() { d.streamFn = streamFn }()
+	s := &streamUnmarshal{d: d}
+	if err = s.decodeValue(b, rv.Elem(), Path{}); err != nil {
+		return err
+	}
+	return s.err
+}
+
+// streamUnmarshal decodes a node from the stream of the decoder straight into a Go value.
+type streamUnmarshal struct {
+	d *Decoder
+	// err is the first part of the node that did not fit into its destination
+	err error
+}
+
+func (s *streamUnmarshal) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// skip reads the rest of the node that starts with b and records the error of it not fitting.
+func (s *streamUnmarshal) skip(b byte, err error) error {
+	if _, derr := s.d.decode(b); derr != nil {
+		return derr
+	}
+	s.fail(err)
+	return nil
+}
+
+// raw reads the rest of the node that starts with b and returns its encoding.
+func (s *streamUnmarshal) raw(b byte) ([]byte, error) {
+	rec := &recorder{reader: s.d.reader, buf: []byte{b}}
+	s.d.reader = rec
+	_, err := s.d.decode(b)
+	s.d.reader = rec.reader
+	return rec.buf, err
+}
+
+// decodeValue reads the rest of the node that starts with b into v.
+// Only the errors of the stream are returned, the others are recorded by fail.
+func (s *streamUnmarshal) decodeValue(b byte, v reflect.Value, path Path) error {
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		raw, err := s.raw(b)
+		if err != nil {
+			return err
+		}
+		if err = v.Addr().Interface().(Unmarshaler).UnmarshalBencode(raw); err != nil {
+			s.fail(wrapPath(path, err))
+		}
+		return nil
+	}
+	if v.Type() == bnCodeType {
+		node, err := s.d.decode(b)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(node))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return s.decodeValue(b, v.Elem(), path)
+	case reflect.Interface:
+		node, err := s.d.decode(b)
+		if err != nil {
+			return err
+		}
+		if v.NumMethod() != 0 {
+			s.fail(typeError(node.State, v.Type(), path))
+			return nil
+		}
+		v.Set(reflect.ValueOf(nativeNode(node)))
+		return nil
+	}
+
+	switch {
+	case b == 'i':
+		node, err := s.d.parseInt(b)
+		if err != nil {
+			return err
+		}
+		if err = setInt(node.Value.(int), v, path); err != nil {
+			s.fail(err)
+		}
+		return nil
+	case b >= '0' && b <= '9':
+		str, err := s.d.parseBytes(b)
+		if err != nil {
+			return err
+		}
+		if err = setString(str, v, path); err != nil {
+			s.fail(err)
+		}
+		return nil
+	case b == 'l':
+		return s.decodeList(v, path)
+	case b == 'd':
+		return s.decodeDict(v, path)
+	default:
+		_, err := s.d.decode(b)
+		return err
+	}
+}
+
+func (s *streamUnmarshal) decodeList(v reflect.Value, path Path) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	case reflect.Array:
+	default:
+		return s.skip('l', typeError(BnList, v.Type(), path))
+	}
+
+	n := 0
+	for ; ; n++ {
+		b, err := s.d.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == 'e' {
+			break
+		}
+		switch {
+		case v.Kind() == reflect.Slice:
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+			err = s.decodeValue(b, v.Index(n), path.Index(n))
+		case n < v.Len():
+			err = s.decodeValue(b, v.Index(n), path.Index(n))
+		default:
+			_, err = s.d.decode(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if v.Kind() == reflect.Array && n > v.Len() {
+		s.fail(wrapPath(path, fmt.Errorf("List of %d elements does not fit into %v", n, v.Type())))
+	}
+	// the rest of a longer array is zeroed, so no stale elements are left behind
+	for i := n; v.Kind() == reflect.Array && i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+	}
+	return nil
+}
+
+func (s *streamUnmarshal) decodeDict(v reflect.Value, path Path) error {
+	var fields map[string]field
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	case reflect.Struct:
+		fields = make(map[string]field)
+		for _, f := range typeFields(v.Type()) {
+			fields[f.name] = f
+		}
+	default:
+		return s.skip('d', typeError(BnDict, v.Type(), path))
+	}
+
+	var prev string
+	for n := 0; ; n++ {
+		b, err := s.d.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == 'e' {
+			return nil
+		}
+		keyBytes, err := s.d.parseBytes(b)
+		if err != nil {
+			return err
+		}
+		key := string(keyBytes)
+		if !s.d.preserveOrder && n > 0 && key < prev {
+			return fmt.Errorf("Dictionary keys are not in lexicographical order")
+		}
+		prev = key
+		if b, err = s.d.reader.ReadByte(); err != nil {
+			return err
+		}
+
+		if v.Kind() == reflect.Map {
+			k, kerr := keyValue(keyBytes, v.Type().Key())
+			if kerr != nil {
+				if err = s.skip(b, wrapPath(path, kerr)); err != nil {
+					return err
+				}
+				continue
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err = s.decodeValue(b, elem, path.Key(key)); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, elem)
+			continue
+		}
+
+		f, ok := fields[key]
+		switch {
+		case ok:
+			err = s.decodeValue(b, fieldByIndex(v, f.index, true), path.Key(f.name))
+		case s.d.disallowUnknownFields:
+			err = s.skip(b, wrapPath(path.Key(key), fmt.Errorf("Unknown field of %v", v.Type())))
+		default:
+			_, err = s.d.decode(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// nativeNode converts the decoded node to the basic Go types, like nativeValue.
+func nativeNode(node BnCode) interface{} {
+	switch v := node.Value.(type) {
+	case []BnCode:
+		rc := make([]interface{}, len(v))
+		for i, elem := range v {
+			rc[i] = nativeNode(elem)
+		}
+		return rc
+	case map[string]BnCode:
+		rc := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			rc[k] = nativeNode(elem)
+		}
+		return rc
+	case OrderedDict:
+		rc := make(map[string]interface{}, len(v))
+		for _, entry := range v {
+			rc[entry.Key] = nativeNode(entry.Value)
+		}
+		return rc
+	default:
+		return v
+	}
+}
+
+// unmarshalState holds the options of a single Unmarshal call.
+type unmarshalState struct {
+	disallowUnknownFields bool
+}
+
+func (s unmarshalState) unmarshal(data []byte, v interface{}) error {
+	root, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Unmarshal target must be a non-nil pointer, got %T", v)
+	}
+	return s.unmarshalValue(root, rv.Elem(), Path{})
+}
+
+func typeError(state State, target reflect.Type, path Path) error {
+	return wrapPath(path, fmt.Errorf("Can not unmarshal %s into %v", state, target))
+}
+
+func (s unmarshalState) unmarshalValue(view View, v reflect.Value, path Path) error {
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		if err := v.Addr().Interface().(Unmarshaler).UnmarshalBencode(view.Raw()); err != nil {
+			return wrapPath(path, err)
+		}
+		return nil
+	}
+	if v.Type() == bnCodeType {
+		node, err := view.Decode()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(node))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return s.unmarshalValue(view, v.Elem(), path)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return typeError(view.State(), v.Type(), path)
+		}
+		native, err := nativeValue(view)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(native))
+		return nil
+	}
+
+	switch view.State() {
+	case BnInt:
+		i, err := view.Int()
+		if err != nil {
+			return err
+		}
+		return setInt(i, v, path)
+	case BnString:
+		b, err := view.Bytes()
+		if err != nil {
+			return err
+		}
+		return setString(b, v, path)
+	case BnList:
+		return s.unmarshalList(view, v, path)
+	default:
+		return s.unmarshalDict(view, v, path)
+	}
+}
+
+// setInt stores the int into an integer or a boolean.
+func setInt(i int, v reflect.Value, path Path) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(i != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(int64(i)) {
+			return wrapPath(path, fmt.Errorf("Value %d overflows %v", i, v.Type()))
+		}
+		v.SetInt(int64(i))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return wrapPath(path, fmt.Errorf("Value %d overflows %v", i, v.Type()))
+		}
+		v.SetUint(uint64(i))
+	default:
+		return typeError(BnInt, v.Type(), path)
+	}
+	return nil
+}
+
+// setString stores the content of a string into a string, a byte slice or a byte array.
+func setString(b []byte, v reflect.Value, path Path) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(b))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(append([]byte{}, b...))
+	case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+		if len(b) != v.Len() {
+			return wrapPath(path, fmt.Errorf("String of %d bytes does not fit into %v", len(b), v.Type()))
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+	default:
+		return typeError(BnString, v.Type(), path)
+	}
+	return nil
+}
+
+func (s unmarshalState) unmarshalList(view View, v reflect.Value, path Path) error {
+	n, _ := view.Len()
+	switch v.Kind() {
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+	case reflect.Array:
+		if n > v.Len() {
+			return wrapPath(path, fmt.Errorf("List of %d elements does not fit into %v", n, v.Type()))
+		}
+	default:
+		return typeError(view.State(), v.Type(), path)
+	}
+
+	for elem, i := view.firstChild(), 0; i < n; elem, i = elem.sibling(), i+1 {
+		if err := s.unmarshalValue(elem, v.Index(i), path.Index(i)); err != nil {
+			return err
+		}
+	}
+	// the rest of a longer array is zeroed, so no stale elements are left behind
+	for i := n; v.Kind() == reflect.Array && i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+	}
+	return nil
+}
+
+// keyValue converts the dictionary key to the map key type.
+func keyValue(key []byte, t reflect.Type) (reflect.Value, error) {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		k := reflect.New(t)
+		err := k.Interface().(encoding.TextUnmarshaler).UnmarshalText(key)
+		return k.Elem(), err
+	}
+	k := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		k.SetString(string(key))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(string(key), 10, t.Bits())
+		if err != nil {
+			return k, err
+		}
+		k.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(string(key), 10, t.Bits())
+		if err != nil {
+			return k, err
+		}
+		k.SetUint(i)
+	default:
+		return k, fmt.Errorf("Unsupported map key type %v", t)
+	}
+	return k, nil
+}
+
+func (s unmarshalState) unmarshalDict(view View, v reflect.Value, path Path) error {
+	n, _ := view.Len()
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		}
+		for keyView, i := view.firstChild(), 0; i < n; keyView, i = keyView.sibling().sibling(), i+1 {
+			key, _ := keyView.Bytes()
+			val := keyView.sibling()
+			k, err := keyValue(key, v.Type().Key())
+			if err != nil {
+				return wrapPath(path, err)
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err = s.unmarshalValue(val, elem, path.Key(string(key))); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, elem)
+		}
+	case reflect.Struct:
+		fields := make(map[string]field)
+		for _, f := range typeFields(v.Type()) {
+			fields[f.name] = f
+		}
+		for keyView, i := view.firstChild(), 0; i < n; keyView, i = keyView.sibling().sibling(), i+1 {
+			key, _ := keyView.Bytes()
+			f, ok := fields[string(key)]
+			if !ok && s.disallowUnknownFields {
+				return wrapPath(path.Key(string(key)), fmt.Errorf("Unknown field of %v", v.Type()))
+			}
+			if !ok {
+				continue
+			}
+			if err := s.unmarshalValue(keyView.sibling(), fieldByIndex(v, f.index, true), path.Key(f.name)); err != nil {
+				return err
+			}
+		}
+	default:
+		return typeError(view.State(), v.Type(), path)
+	}
+	return nil
+}
+
+// nativeValue converts the node to the basic Go types.
+func nativeValue(view View) (interface{}, error) {
+	switch view.State() {
+	case BnInt:
+		return view.Int()
+	case BnString:
+		return view.Str()
+	case BnList:
+		n, _ := view.Len()
+		rc := make([]interface{}, n)
+		for elem, i := view.firstChild(), 0; i < n; elem, i = elem.sibling(), i+1 {
+			var err error
+			if rc[i], err = nativeValue(elem); err != nil {
+				return nil, err
+			}
+		}
+		return rc, nil
+	default:
+		n, _ := view.Len()
+		rc := make(map[string]interface{}, n)
+		for key, i := view.firstChild(), 0; i < n; key, i = key.sibling().sibling(), i+1 {
+			k, _ := key.Str()
+			val, err := nativeValue(key.sibling())
+			if err != nil {
+				return nil, err
+			}
+			rc[k] = val
+		}
+		return rc, nil
+	}
+}
@@ -0,0 +1,240 @@
+package bencode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	var got torrent
+	data := "d8:announce3:url7:comment1:c4:hash4:abcd5:peers12:aaaaaabbbbbb7:privatei1e7:unknowni5e1:vli1ei2eee"
+	if err := Unmarshal([]byte(data), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := torrent{
+		Common:   Common{Comment: "c"},
+		Announce: "url",
+		Private:  true,
+		Hash:     [4]byte{'a', 'b', 'c', 'd'},
+		Peers:    compactPeers{"aaaaaa", "bbbbbb"},
+		Version:  version{1, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshal_Values(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		target  interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:    "Empty interface",
+			data:    "d1:ali1e1:be1:bi-2ee",
+			target:  new(interface{}),
+			want:    map[string]interface{}{"a": []interface{}{1, "b"}, "b": -2},
+			wantErr: false,
+		},
+		{
+			name:    "Text keys",
+			data:    "d1:Ai2e1:Bi1ee",
+			target:  new(map[upperKey]int),
+			want:    map[upperKey]int{"a": 2, "b": 1},
+			wantErr: false,
+		},
+		{
+			name:    "Int keys",
+			data:    "d2:101:x1:21:ye",
+			target:  new(map[int]string),
+			want:    map[int]string{10: "x", 2: "y"},
+			wantErr: false,
+		},
+		{
+			name:    "BnCode",
+			data:    "li1ee",
+			target:  new(BnCode),
+			want:    BnCode{State: BnList, Value: []BnCode{{State: BnInt, Value: 1}}},
+			wantErr: false,
+		},
+		{
+			name:    "Pointer",
+			data:    "i7e",
+			target:  new(*uint16),
+			want:    func() *uint16 { v := uint16(7); return &v }(),
+			wantErr: false,
+		},
+		{
+			name:    "Overflow",
+			data:    "i300e",
+			target:  new(uint8),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Negative into unsigned",
+			data:    "i-1e",
+			target:  new(uint),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Type mismatch",
+			data:    "d1:ai1ee",
+			target:  new(map[string]string),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Array length mismatch",
+			data:    "3:abc",
+			target:  new([4]byte),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Custom decoding error",
+			data:    "5:abcde",
+			target:  new(compactPeers),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid data",
+			data:    "li1e",
+			target:  new([]int),
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Unmarshal([]byte(tt.data), tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got := reflect.ValueOf(tt.target).Elem().Interface(); !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %#v, want %#v", got, tt.want)
+			}
+
+			target := reflect.New(reflect.TypeOf(tt.target).Elem())
+			err = NewDecoder(strings.NewReader(tt.data)).DecodeInto(target.Interface())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decoder.DecodeInto() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got := target.Elem().Interface(); !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decoder.DecodeInto() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_NonPointer(t *testing.T) {
+	var v int
+	if err := Unmarshal([]byte("i1e"), v); err == nil {
+		t.Errorf("Unmarshal() accepted a non-pointer target")
+	}
+}
+
+type config struct {
+	Name    string            `bencode:"name"`
+	Port    int               `bencode:"port"`
+	Limits  map[string]int    `bencode:"limits"`
+	Peer    *config           `bencode:"peer"`
+	Hash    [3]int            `bencode:"hash"`
+	Labels  map[string]string `bencode:"labels"`
+	Enabled bool              `bencode:"enabled"`
+}
+
+func TestUnmarshal_Merge(t *testing.T) {
+	peer := &config{Name: "peer", Port: 1}
+	got := config{
+		Name:    "default",
+		Port:    6881,
+		Limits:  map[string]int{"up": 10, "down": 20},
+		Peer:    peer,
+		Hash:    [3]int{7, 8, 9},
+		Enabled: true,
+	}
+	data := "d4:hashli1ee6:limitsd4:downi5ee4:peerd4:porti2eee"
+	if err := Unmarshal([]byte(data), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := config{
+		Name:    "default",
+		Port:    6881,
+		Limits:  map[string]int{"up": 10, "down": 5},
+		Peer:    &config{Name: "peer", Port: 2},
+		Hash:    [3]int{1, 0, 0},
+		Enabled: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+	if got.Peer != peer {
+		t.Errorf("Unmarshal() replaced the existing pointer")
+	}
+}
+
+func TestDecoder_DecodeInto(t *testing.T) {
+	d := NewDecoder(strings.NewReader("d4:name1:ae" + "d4:peerd5:extrai1eee"))
+	var first config
+	if err := d.DecodeInto(&first); err != nil || first.Name != "a" {
+		t.Fatalf("Decoder.DecodeInto() = %+v, %v", first, err)
+	}
+
+	d.DisallowUnknownFields()
+	var second config
+	err := d.DecodeInto(&second)
+	if err == nil || err.Error() != "peer.extra: Unknown field of bencode.config" {
+		t.Errorf("Decoder.DecodeInto() error = %v", err)
+	}
+
+	// unknown keys are still accepted by maps
+	d = NewDecoder(strings.NewReader("d6:labelsd1:x1:yee"))
+	d.DisallowUnknownFields()
+	var third config
+	if err = d.DecodeInto(&third); err != nil || third.Labels["x"] != "y" {
+		t.Errorf("Decoder.DecodeInto() = %+v, %v", third, err)
+	}
+}
+
+func TestDecoder_DecodeInto_Options(t *testing.T) {
+	// unsorted keys are only accepted with PreserveOrder, the last of duplicate keys wins
+	data := "d4:portd1:bi1e1:ai2ee4:name1:x4:name1:ye"
+	var got config
+	if err := NewDecoder(strings.NewReader(data)).DecodeInto(&got); err == nil {
+		t.Errorf("Decoder.DecodeInto() accepted unsorted keys")
+	}
+	d := NewDecoder(strings.NewReader(data))
+	d.PreserveOrder()
+	got = config{}
+	if err := d.DecodeInto(&got); err == nil || err.Error() != "port: Can not unmarshal dict into int" {
+		t.Errorf("Decoder.DecodeInto() error = %v", err)
+	}
+	if got.Name != "y" {
+		t.Errorf("Decoder.DecodeInto() = %+v, want the last name", got)
+	}
+
+	d = NewDecoder(strings.NewReader("d4:name5:helloe"))
+	d.MaxStringLength(4)
+	if err := d.DecodeInto(&got); err == nil {
+		t.Errorf("Decoder.DecodeInto() accepted a string over the limit")
+	}
+
+	// the node that does not fit is read to its end, so the next one decodes
+	d = NewDecoder(strings.NewReader("d4:hashl1:aee" + "d4:porti1ee"))
+	if err := d.DecodeInto(&got); err == nil {
+		t.Errorf("Decoder.DecodeInto() accepted a list of strings into [3]int")
+	}
+	got = config{}
+	if err := d.DecodeInto(&got); err != nil || got.Port != 1 {
+		t.Errorf("Decoder.DecodeInto() = %+v, %v", got, err)
+	}
+}
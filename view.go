@@ -0,0 +1,494 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// entry is a single node of the offset index built by Parse, entries are stored in pre-order.
+type entry struct {
+	// start is the offset of the first byte of the node
+	start uint32
+	// end is the offset past the last byte of the node
+	end uint32
+	// next is the index of the entry that follows the subtree of the node
+	next uint32
+	// n is the number of elements of a list, key value pairs of a dictionary
+	// or the offset of the content of a string
+	n uint32
+}
+
+// View is a lightweight handle to a node of the data passed to Parse.
+// Values are decoded on demand, strings are returned as sub slices of the original data.
+//
+// The zero View is invalid, all of its methods return errors.
+type View struct {
+	data  []byte
+	index []entry
+	pos   int
+}
+
+type indexer struct {
+	data  []byte
+	index []entry
+}
+
+func (p *indexer) errorf(offset int, format string, args ...interface{}) error {
+	return fmt.Errorf("Offset %d: %s", offset, fmt.Sprintf(format, args...))
+}
+
+// intEnd validates the int starting at offset and returns the offset past its terminator.
+func (p *indexer) intEnd(offset int) (int, error) {
+	i := offset + 1
+	if i < len(p.data) && p.data[i] == '-' {
+		i++
+	}
+	digits := i
+	for i < len(p.data) && p.data[i] >= '0' && p.data[i] <= '9' {
+		i++
+	}
+	if i >= len(p.data) {
+		return 0, p.errorf(offset, "Unterminated int")
+	}
+	if p.data[i] != 'e' {
+		return 0, p.errorf(i, "Unexpected character encountered. Expected a digit, sign or e, got %c", p.data[i])
+	}
+	switch {
+	case i == digits:
+		return 0, p.errorf(offset, "Empty int")
+	case p.data[digits] == '0' && i-digits > 1:
+		return 0, p.errorf(offset, "Leading zeros are not allowed")
+	case p.data[digits] == '0' && digits > offset+1:
+		return 0, p.errorf(offset, "Negative zeros are not allowed")
+	}
+	if _, err := parseDigits(p.data[offset+1 : i]); err != nil {
+		return 0, p.errorf(offset, "%v", err)
+	}
+	return i + 1, nil
+}
+
+// stringEnd validates the string starting at offset and returns the offsets of its content and past its end.
+func (p *indexer) stringEnd(offset int) (int, int, error) {
+	i := offset
+	for i < len(p.data) && p.data[i] >= '0' && p.data[i] <= '9' {
+		i++
+	}
+	if i >= len(p.data) {
+		return 0, 0, p.errorf(offset, "Unterminated string length")
+	}
+	if p.data[i] != ':' {
+		return 0, 0, p.errorf(i, "Unexpected character '%c' encountered while parse string", p.data[i])
+	}
+	length, err := parseDigits(p.data[offset:i])
+	if err != nil {
+		return 0, 0, p.errorf(offset, "%v", err)
+	}
+	if length > len(p.data)-i-1 {
+		return 0, 0, p.errorf(offset, "String of %d bytes exceeds the data", length)
+	}
+	return i + 1, i + 1 + length, nil
+}
+
+// node validates the node starting at offset, appends it and all of its children to the index
+// and returns the offset past its end.
+func (p *indexer) node(offset int) (int, error) {
+	if offset >= len(p.data) {
+		return 0, p.errorf(offset, "Unexpected end of data")
+	}
+	pos := len(p.index)
+	p.index = append(p.index, entry{start: uint32(offset)})
+
+	var end, n int
+	var err error
+	switch c := p.data[offset]; {
+	case c == 'i':
+		end, err = p.intEnd(offset)
+	case c >= '0' && c <= '9':
+		n, end, err = p.stringEnd(offset)
+	case c == 'l':
+		end = offset + 1
+		for ; end < len(p.data) && p.data[end] != 'e'; n++ {
+			if end, err = p.node(end); err != nil {
+				return 0, err
+			}
+		}
+		if end >= len(p.data) {
+			return 0, p.errorf(offset, "Unterminated list")
+		}
+		end++
+	case c == 'd':
+		var prevKey []byte
+		end = offset + 1
+		for ; end < len(p.data) && p.data[end] != 'e'; n++ {
+			if c := p.data[end]; c < '0' || c > '9' {
+				return 0, p.errorf(end, "Dictionary key must be a string")
+			}
+			key := len(p.index)
+			if end, err = p.node(end); err != nil {
+				return 0, err
+			}
+			keyBytes := p.data[p.index[key].n:end]
+			if n > 0 && bytes.Compare(prevKey, keyBytes) > 0 {
+				return 0, p.errorf(int(p.index[key].start), "Dictionary keys are not in lexicographical order")
+			}
+			prevKey = keyBytes
+			if end, err = p.node(end); err != nil {
+				return 0, err
+			}
+		}
+		if end >= len(p.data) {
+			return 0, p.errorf(offset, "Unterminated dictionary")
+		}
+		end++
+	default:
+		return 0, p.errorf(offset, "Unexpected character %c", c)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	p.index[pos].end = uint32(end)
+	p.index[pos].next = uint32(len(p.index))
+	p.index[pos].n = uint32(n)
+	return end, nil
+}
+
+// Parse validates data in a single pass and builds a compact index of the offsets of all the nodes.
+// The returned View points to the root node, the data must not be modified while it is in use.
+//
+// Returns error if data does not hold exactly one valid node or is larger than 4GB.
+func Parse(data []byte) (View, error) {
+	if uint64(len(data)) > math.MaxUint32 {
+		return View{}, fmt.Errorf("Data of %d bytes is too large to index", len(data))
+	}
+	p := &indexer{data: data}
+	end, err := p.node(0)
+	if err != nil {
+		return View{}, err
+	}
+	if end != len(data) {
+		return View{}, fmt.Errorf("Unexpected %d trailing bytes after the root node", len(data)-end)
+	}
+	return View{data: data, index: p.index}, nil
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// parseDigits converts the optionally signed decimal number, the digits are expected to be validated.
+func parseDigits(b []byte) (int, error) {
+	neg := len(b) > 0 && b[0] == '-'
+	if neg {
+		b = b[1:]
+	}
+	rc := 0
+	for _, c := range b {
+		d := int(c - '0')
+		if rc > (maxInt-d)/10 {
+			return 0, fmt.Errorf("Value %s is out of range", b)
+		}
+		rc = rc*10 + d
+	}
+	if neg {
+		rc = -rc
+	}
+	return rc, nil
+}
+
+func (v View) entry() (entry, error) {
+	if v.pos >= len(v.index) {
+		return entry{}, fmt.Errorf("Invalid view")
+	}
+	return v.index[v.pos], nil
+}
+
+// firstChild returns the first element of a list or the first key of a dictionary,
+// the node has to have children.
+func (v View) firstChild() View {
+	return View{data: v.data, index: v.index, pos: v.pos + 1}
+}
+
+// sibling returns the node that follows the subtree of the current one within the same parent.
+func (v View) sibling() View {
+	return View{data: v.data, index: v.index, pos: int(v.index[v.pos].next)}
+}
+
+// State returns the type of the node, one of BnInt, BnString, BnList or BnDict.
+// Returns -1 for the invalid View.
+func (v View) State() State {
+	e, err := v.entry()
+	if err != nil {
+		return -1
+	}
+	switch v.data[e.start] {
+	case 'i':
+		return BnInt
+	case 'l':
+		return BnList
+	case 'd':
+		return BnDict
+	default:
+		return BnString
+	}
+}
+
+// Raw returns the encoded bytes of the node.
+func (v View) Raw() []byte {
+	e, err := v.entry()
+	if err != nil {
+		return nil
+	}
+	return v.data[e.start:e.end:e.end]
+}
+
+// Offset returns the position of the first byte of the node in the parsed data.
+// Returns -1 for the invalid View.
+func (v View) Offset() int {
+	e, err := v.entry()
+	if err != nil {
+		return -1
+	}
+	return int(e.start)
+}
+
+// Len returns the number of elements of a list, key value pairs of a dictionary or bytes of a string.
+//
+// Returns error if the node is an int.
+func (v View) Len() (int, error) {
+	e, err := v.entry()
+	if err != nil {
+		return 0, err
+	}
+	switch v.State() {
+	case BnString:
+		return int(e.end - e.n), nil
+	case BnList, BnDict:
+		return int(e.n), nil
+	default:
+		return 0, fmt.Errorf("Given Value is not a string, list or dictionary")
+	}
+}
+
+// Int decodes the int node.
+func (v View) Int() (int, error) {
+	e, err := v.entry()
+	if err != nil {
+		return 0, err
+	}
+	if v.State() != BnInt {
+		return 0, fmt.Errorf("Given Value is not an int")
+	}
+	return parseDigits(v.data[e.start+1 : e.end-1])
+}
+
+// Bytes returns the content of the string node without copying it.
+// The returned slice must not be modified.
+func (v View) Bytes() ([]byte, error) {
+	e, err := v.entry()
+	if err != nil {
+		return nil, err
+	}
+	if v.State() != BnString {
+		return nil, fmt.Errorf("Given Value is not a string")
+	}
+	return v.data[e.n:e.end:e.end], nil
+}
+
+// Str returns the content of the string node as a string, which is a copy of the data.
+func (v View) Str() (string, error) {
+	b, err := v.Bytes()
+	return string(b), err
+}
+
+// Index returns the i-th element of the list node.
+func (v View) Index(i int) (View, error) {
+	e, err := v.entry()
+	if err != nil {
+		return View{}, err
+	}
+	if v.State() != BnList {
+		return View{}, fmt.Errorf("Given Value is not a list")
+	}
+	if i < 0 || i >= int(e.n) {
+		return View{}, fmt.Errorf("Index %d is out of range [0, %d)", i, e.n)
+	}
+	pos := v.pos + 1
+	for ; i > 0; i-- {
+		pos = int(v.index[pos].next)
+	}
+	return View{data: v.data, index: v.index, pos: pos}, nil
+}
+
+// Entry returns the key and the value of the i-th pair of the dictionary node.
+// The key is a sub slice of the original data and must not be modified.
+func (v View) Entry(i int) ([]byte, View, error) {
+	e, err := v.entry()
+	if err != nil {
+		return nil, View{}, err
+	}
+	if v.State() != BnDict {
+		return nil, View{}, fmt.Errorf("Given Value is not a dictionary")
+	}
+	if i < 0 || i >= int(e.n) {
+		return nil, View{}, fmt.Errorf("Index %d is out of range [0, %d)", i, e.n)
+	}
+	pos := v.pos + 1
+	for ; i > 0; i-- {
+		pos = int(v.index[v.index[pos].next].next)
+	}
+	key := View{data: v.data, index: v.index, pos: pos}
+	keyBytes, _ := key.Bytes()
+	return keyBytes, View{data: v.data, index: v.index, pos: int(v.index[pos].next)}, nil
+}
+
+// Get returns the value of the given key of the dictionary node.
+// Like Decode, the last of duplicate keys wins.
+func (v View) Get(key string) (View, error) {
+	e, err := v.entry()
+	if err != nil {
+		return View{}, err
+	}
+	if v.State() != BnDict {
+		return View{}, fmt.Errorf("Given Value is not a dictionary")
+	}
+	found := -1
+	pos := v.pos + 1
+	for i := 0; i < int(e.n); i++ {
+		k := v.index[pos]
+		val := int(k.next)
+		current := v.data[k.n:k.end]
+		if string(current) > key {
+			// the keys are sorted, so equal ones are next to each other
+			break
+		}
+		if string(current) == key {
+			found = val
+		}
+		pos = int(v.index[val].next)
+	}
+	if found < 0 {
+		return View{}, fmt.Errorf("Key %q is not found", key)
+	}
+	return View{data: v.data, index: v.index, pos: found}, nil
+}
+
+// Path follows the dictionary keys and list indexes, given in their decimal form, starting from the node.
+func (v View) Path(elems ...string) (View, error) {
+	rc := v
+	var err error
+	for _, elem := range elems {
+		if rc.State() == BnList {
+			i, convErr := strconv.Atoi(elem)
+			if convErr != nil {
+				return View{}, fmt.Errorf("Invalid list index %q", elem)
+			}
+			rc, err = rc.Index(i)
+		} else {
+			rc, err = rc.Get(elem)
+		}
+		if err != nil {
+			return View{}, err
+		}
+	}
+	return rc, nil
+}
+
+// Decode materializes the node and all of its children into BnCode.
+func (v View) Decode() (BnCode, error) {
+	if _, err := v.entry(); err != nil {
+		return BnCode{}, err
+	}
+	switch v.State() {
+	case BnInt:
+		i, err := v.Int()
+		return BnCode{State: BnInt, Value: i}, err
+	case BnString:
+		s, err := v.Str()
+		return BnCode{State: BnString, Value: s}, err
+	case BnList:
+		n, _ := v.Len()
+		list := make([]BnCode, 0, n)
+		for elem, i := v.firstChild(), 0; i < n; elem, i = elem.sibling(), i+1 {
+			val, err := elem.Decode()
+			if err != nil {
+				return BnCode{}, err
+			}
+			list = append(list, val)
+		}
+		return BnCode{State: BnList, Value: list}, nil
+	default:
+		n, _ := v.Len()
+		dict := make(map[string]BnCode, n)
+		for key, i := v.firstChild(), 0; i < n; key, i = key.sibling().sibling(), i+1 {
+			k, _ := key.Str()
+			val, err := key.sibling().Decode()
+			if err != nil {
+				return BnCode{}, err
+			}
+			dict[k] = val
+		}
+		return BnCode{State: BnDict, Value: dict}, nil
+	}
+}
+
+// Iter iterates over the elements of a list node or the key value pairs of a dictionary node.
+// Unlike Index and Entry, it visits all the children in linear time and without allocations.
+type Iter struct {
+	cur     View
+	dict    bool
+	left    int
+	started bool
+}
+
+// Iter returns an iterator over the children of the list or dictionary node.
+//
+// Returns error if the node is an int or a string.
+func (v View) Iter() (Iter, error) {
+	e, err := v.entry()
+	if err != nil {
+		return Iter{}, err
+	}
+	switch v.State() {
+	case BnList, BnDict:
+		return Iter{cur: v, dict: v.State() == BnDict, left: int(e.n)}, nil
+	default:
+		return Iter{}, fmt.Errorf("Given Value is not a list or dictionary")
+	}
+}
+
+// Next advances the iterator to the next child and reports whether there is one.
+func (it *Iter) Next() bool {
+	if it.left == 0 {
+		return false
+	}
+	it.left--
+	if !it.started {
+		it.cur = it.cur.firstChild()
+		it.started = true
+		return true
+	}
+	it.cur = it.cur.sibling()
+	if it.dict {
+		it.cur = it.cur.sibling()
+	}
+	return true
+}
+
+// Key returns the key of the current dictionary pair as a sub slice of the original data, nil for lists.
+// The key must not be modified.
+func (it *Iter) Key() []byte {
+	if !it.dict {
+		return nil
+	}
+	b, _ := it.cur.Bytes()
+	return b
+}
+
+// Value returns the current list element or the value of the current dictionary pair.
+func (it *Iter) Value() View {
+	if it.dict {
+		return it.cur.sibling()
+	}
+	return it.cur
+}
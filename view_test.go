@@ -0,0 +1,167 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const viewSample = "d8:announce3:url4:infod6:lengthi-42e4:name10:ubuntu.iso6:piecesl1:a2:bb3:ccceee"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "Positive test case", data: viewSample, wantErr: false},
+		{name: "Empty list", data: "le", wantErr: false},
+		{name: "Empty data", data: "", wantErr: true},
+		{name: "Trailing data", data: "i1ei2e", wantErr: true},
+		{name: "Unterminated list", data: "li1e", wantErr: true},
+		{name: "Unterminated dictionary", data: "d1:ai1e", wantErr: true},
+		{name: "String exceeds the data", data: "5:abc", wantErr: true},
+		{name: "Leading zero", data: "i01e", wantErr: true},
+		{name: "Negative zero", data: "i-0e", wantErr: true},
+		{name: "Empty int", data: "ie", wantErr: true},
+		{name: "Int out of range", data: "i99999999999999999999e", wantErr: true},
+		{name: "Unsorted keys", data: "d1:bi1e1:ai2ee", wantErr: true},
+		{name: "Int key", data: "di1ei2ee", wantErr: true},
+		{name: "Unknown character", data: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	data := []byte(viewSample)
+	root, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	name, err := root.Path("info", "name")
+	if err != nil {
+		t.Fatalf("View.Path() error = %v", err)
+	}
+	b, err := name.Bytes()
+	if err != nil || string(b) != "ubuntu.iso" {
+		t.Errorf("View.Bytes() = %q, %v, want %q", b, err, "ubuntu.iso")
+	}
+	if &b[0] != &data[45] {
+		t.Errorf("View.Bytes() copied the string data")
+	}
+
+	length, _ := root.Path("info", "length")
+	if i, err := length.Int(); err != nil || i != -42 {
+		t.Errorf("View.Int() = %d, %v, want -42", i, err)
+	}
+
+	pieces, _ := root.Path("info", "pieces")
+	if n, err := pieces.Len(); err != nil || n != 3 {
+		t.Errorf("View.Len() = %d, %v, want 3", n, err)
+	}
+	third, err := pieces.Index(2)
+	if s, _ := third.Str(); err != nil || s != "ccc" {
+		t.Errorf("View.Index() = %q, %v, want %q", s, err, "ccc")
+	}
+	if _, err = pieces.Index(3); err == nil {
+		t.Errorf("View.Index() accepted an index out of range")
+	}
+
+	key, val, err := root.Entry(1)
+	if err != nil || string(key) != "info" || val.State() != BnDict {
+		t.Errorf("View.Entry() = %q, %v, %v", key, val.State(), err)
+	}
+	if string(val.Raw()) != viewSample[22:len(viewSample)-1] {
+		t.Errorf("View.Raw() = %q", val.Raw())
+	}
+
+	if _, err = root.Get("missing"); err == nil {
+		t.Errorf("View.Get() found a missing key")
+	}
+	if _, err = name.Int(); err == nil {
+		t.Errorf("View.Int() converted a string")
+	}
+	if _, err = (View{}).Len(); err == nil {
+		t.Errorf("View.Len() succeeded on the zero View")
+	}
+	if got := (View{}).Offset(); got != -1 {
+		t.Errorf("View.Offset() = %d for the zero View, want -1", got)
+	}
+
+	// duplicate keys resolve to the last one, like Decode does
+	dup, _ := Parse([]byte("d1:ai1e1:bi2e1:bi3e1:ci4ee"))
+	last, err := dup.Get("b")
+	if n, _ := last.Int(); err != nil || n != 3 {
+		t.Errorf("View.Get() = %d, %v, want the last duplicate 3", n, err)
+	}
+	if _, err = dup.Get("bb"); err == nil {
+		t.Errorf("View.Get() found a missing key")
+	}
+}
+
+func TestView_Decode(t *testing.T) {
+	root, err := Parse([]byte(viewSample))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := root.Decode()
+	if err != nil {
+		t.Fatalf("View.Decode() error = %v", err)
+	}
+	want, err := Decode(bytes.NewReader([]byte(viewSample)))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("View.Decode() = %v, want %v", got, want)
+	}
+}
+
+func TestView_Iter(t *testing.T) {
+	root, err := Parse([]byte(viewSample))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	it, err := root.Iter()
+	if err != nil {
+		t.Fatalf("Iter() error = %v", err)
+	}
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if want := []string{"announce", "info"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Iter() keys = %v, want %v", keys, want)
+	}
+
+	pieces, _ := root.Path("info", "pieces")
+	it, _ = pieces.Iter()
+	var values []string
+	for it.Next() {
+		if it.Key() != nil {
+			t.Errorf("Iter().Key() = %q for a list element", it.Key())
+		}
+		s, _ := it.Value().Str()
+		values = append(values, s)
+	}
+	if want := []string{"a", "bb", "ccc"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Iter() values = %v, want %v", values, want)
+	}
+
+	empty, _ := Parse([]byte("de"))
+	if it, _ = empty.Iter(); it.Next() {
+		t.Errorf("Iter().Next() = true for an empty dictionary")
+	}
+	length, _ := root.Path("info", "length")
+	if _, err = length.Iter(); err == nil {
+		t.Errorf("Iter() accepted an int")
+	}
+}
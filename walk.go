@@ -0,0 +1,199 @@
+package bencode
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// SkipChildren is returned by the Walk or Transform callback to leave the children of the node unvisited.
+	SkipChildren = errors.New("skip children")
+	// Stop is returned by the Walk or Transform callback to end the traversal without an error.
+	Stop = errors.New("stop")
+	// Delete is returned by the Transform callback to remove the node from its parent.
+	Delete = errors.New("delete")
+)
+
+// PathElem is a single step of a Path, either a dictionary key or a list index.
+type PathElem struct {
+	Key   string
+	Index int
+	// InList tells that the element is a list index rather than a dictionary key
+	InList bool
+}
+
+// Path is the location of a node relative to the root of the tree, the root itself has an empty path.
+type Path []PathElem
+
+// Key returns a new path extended with the dictionary key.
+func (p Path) Key(key string) Path {
+	return append(p[:len(p):len(p)], PathElem{Key: key})
+}
+
+// Index returns a new path extended with the list index.
+func (p Path) Index(i int) Path {
+	return append(p[:len(p):len(p)], PathElem{Index: i, InList: true})
+}
+
+// String formats the path as dot separated keys with indexes in brackets, e.g. info.files[0].length
+func (p Path) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.InList {
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(e.Index))
+			sb.WriteByte(']')
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(e.Key)
+	}
+	return sb.String()
+}
+
+// sortedKeys returns the keys of the dictionary in the encoding order.
+func sortedKeys(dict map[string]BnCode) []string {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Walk calls fn for the node and all of its descendants in depth first order.
+// Dictionary entries are visited in the order of their keys, OrderedDict entries in their own order.
+//
+// The callback can return SkipChildren to not descend into the current node, or Stop to end the walk.
+// Any other error aborts the walk and is returned by Walk.
+func Walk(node BnCode, fn func(path Path, n BnCode) error) error {
+	if err := walk(Path{}, node, fn); err != nil && err != Stop {
+		return err
+	}
+	return nil
+}
+
+func walk(path Path, node BnCode, fn func(path Path, n BnCode) error) error {
+	if err := fn(path, node); err == SkipChildren {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch node.State {
+	case BnList:
+		list, err := node.GetList()
+		if err != nil {
+			return err
+		}
+		for i, v := range list {
+			if err = walk(path.Index(i), v, fn); err != nil {
+				return err
+			}
+		}
+	case BnDict:
+		if ordered, ok := node.Value.(OrderedDict); ok {
+			for _, e := range ordered {
+				if err := walk(path.Key(e.Key), e.Value, fn); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		dict, err := node.GetDict()
+		if err != nil {
+			return err
+		}
+		for _, k := range sortedKeys(dict) {
+			if err = walk(path.Key(k), dict[k], fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Transform builds a copy of the tree, where every node is replaced with the result of fn.
+// The callback is called before the children are visited, so the children of the replacement are transformed.
+//
+// Besides SkipChildren and Stop, the callback can return Delete to drop the node from its parent list or dictionary.
+// Once the transformation is stopped, the rest of the nodes are copied as is. The source tree is never modified.
+func Transform(node BnCode, fn func(path Path, n BnCode) (BnCode, error)) (BnCode, error) {
+	stopped := false
+	rc, err := transform(Path{}, node, fn, &stopped)
+	if err == Delete {
+		return BnCode{}, errors.New("Root node can not be deleted")
+	}
+	return rc, err
+}
+
+func transform(path Path, node BnCode, fn func(path Path, n BnCode) (BnCode, error), stopped *bool) (BnCode, error) {
+	if *stopped {
+		return node, nil
+	}
+
+	rc, err := fn(path, node)
+	switch err {
+	case nil:
+	case SkipChildren:
+		return rc, nil
+	case Stop:
+		*stopped = true
+		return rc, nil
+	default:
+		return rc, err
+	}
+
+	switch rc.State {
+	case BnList:
+		list, err := rc.GetList()
+		if err != nil {
+			return rc, err
+		}
+		out := make([]BnCode, 0, len(list))
+		for i, v := range list {
+			t, err := transform(path.Index(i), v, fn, stopped)
+			if err == Delete {
+				continue
+			} else if err != nil {
+				return rc, err
+			}
+			out = append(out, t)
+		}
+		return BnCode{State: BnList, Value: out}, nil
+	case BnDict:
+		if ordered, ok := rc.Value.(OrderedDict); ok {
+			out := make(OrderedDict, 0, len(ordered))
+			for _, e := range ordered {
+				t, err := transform(path.Key(e.Key), e.Value, fn, stopped)
+				if err == Delete {
+					continue
+				} else if err != nil {
+					return rc, err
+				}
+				out = append(out, DictEntry{Key: e.Key, Value: t})
+			}
+			return BnCode{State: BnDict, Value: out}, nil
+		}
+		dict, err := rc.GetDict()
+		if err != nil {
+			return rc, err
+		}
+		out := make(map[string]BnCode, len(dict))
+		for _, k := range sortedKeys(dict) {
+			t, err := transform(path.Key(k), dict[k], fn, stopped)
+			if err == Delete {
+				continue
+			} else if err != nil {
+				return rc, err
+			}
+			out[k] = t
+		}
+		return BnCode{State: BnDict, Value: out}, nil
+	}
+	return rc, nil
+}
@@ -0,0 +1,156 @@
+package bencode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func walkSample() BnCode {
+	return BnCode{State: BnDict, Value: map[string]BnCode{
+		"announce": {State: BnString, Value: "http://tracker/announce"},
+		"info": {State: BnDict, Value: map[string]BnCode{
+			"files": {State: BnList, Value: []BnCode{
+				{State: BnDict, Value: map[string]BnCode{
+					"length": {State: BnInt, Value: 1},
+					"path":   {State: BnList, Value: []BnCode{{State: BnString, Value: "a.txt"}}},
+				}},
+			}},
+			"private": {State: BnInt, Value: 1},
+		}},
+	}}
+}
+
+func TestPath_String(t *testing.T) {
+	tests := []struct {
+		name string
+		path Path
+		want string
+	}{
+		{name: "Root", path: Path{}, want: ""},
+		{name: "Keys and indexes", path: Path{}.Key("info").Key("files").Index(0).Key("path").Index(2), want: "info.files[0].path[2]"},
+		{name: "List root", path: Path{}.Index(1).Key("a"), want: "[1].a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.path.String(); got != tt.want {
+				t.Errorf("Path.String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var visited []string
+	err := Walk(walkSample(), func(path Path, n BnCode) error {
+		visited = append(visited, path.String())
+		if path.String() == "info.files[0]" {
+			return SkipChildren
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	want := []string{"", "announce", "info", "info.files", "info.files[0]", "info.private"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Walk() visited %v, want %v", visited, want)
+	}
+}
+
+func TestWalk_Stop(t *testing.T) {
+	count := 0
+	err := Walk(walkSample(), func(path Path, n BnCode) error {
+		count++
+		if n.State == BnString {
+			return Stop
+		}
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Errorf("Walk() = %v after %d nodes, want nil after 2", err, count)
+	}
+
+	failure := errors.New("failure")
+	err = Walk(walkSample(), func(path Path, n BnCode) error {
+		return failure
+	})
+	if err != failure {
+		t.Errorf("Walk() = %v, want %v", err, failure)
+	}
+
+	err = Walk(BnCode{State: BnList, Value: "broken"}, func(path Path, n BnCode) error {
+		return nil
+	})
+	if err == nil {
+		t.Errorf("Walk() accepted a broken list")
+	}
+}
+
+func TestTransform(t *testing.T) {
+	src := walkSample()
+	got, err := Transform(src, func(path Path, n BnCode) (BnCode, error) {
+		switch {
+		case path.String() == "info.private":
+			return n, Delete
+		case n.State == BnString:
+			s, _ := n.GetString()
+			return BnCode{State: BnString, Value: s + "!"}, nil
+		}
+		return n, nil
+	})
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+
+	want := BnCode{State: BnDict, Value: map[string]BnCode{
+		"announce": {State: BnString, Value: "http://tracker/announce!"},
+		"info": {State: BnDict, Value: map[string]BnCode{
+			"files": {State: BnList, Value: []BnCode{
+				{State: BnDict, Value: map[string]BnCode{
+					"length": {State: BnInt, Value: 1},
+					"path":   {State: BnList, Value: []BnCode{{State: BnString, Value: "a.txt!"}}},
+				}},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(src, walkSample()) {
+		t.Errorf("Transform() modified the source tree")
+	}
+}
+
+func TestTransform_OrderedDict(t *testing.T) {
+	src := BnCode{State: BnDict, Value: OrderedDict{
+		{Key: "b", Value: BnCode{State: BnInt, Value: 1}},
+		{Key: "a", Value: BnCode{State: BnInt, Value: 2}},
+		{Key: "b", Value: BnCode{State: BnInt, Value: 3}},
+	}}
+	got, err := Transform(src, func(path Path, n BnCode) (BnCode, error) {
+		if n.State == BnInt {
+			i, _ := n.GetInt()
+			if i == 2 {
+				return n, Stop
+			}
+			return BnCode{State: BnInt, Value: i * 10}, nil
+		}
+		return n, nil
+	})
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	want := BnCode{State: BnDict, Value: OrderedDict{
+		{Key: "b", Value: BnCode{State: BnInt, Value: 10}},
+		{Key: "a", Value: BnCode{State: BnInt, Value: 2}},
+		{Key: "b", Value: BnCode{State: BnInt, Value: 3}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform() = %v, want %v", got, want)
+	}
+
+	if _, err = Transform(src, func(path Path, n BnCode) (BnCode, error) { return n, Delete }); err == nil {
+		t.Errorf("Transform() deleted the root node")
+	}
+}